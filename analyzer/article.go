@@ -0,0 +1,303 @@
+package analyzer
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+)
+
+// Article is the main content of a page with the navigation, sidebars, footers and comments removed
+type Article struct {
+	Title     string `json:"title"`
+	Byline    string `json:"byline"`
+	Excerpt   string `json:"excerpt"`
+	LeadImage string `json:"lead_image"`
+	// Content is a cleaned copy of the main content, the page passed to Article is never changed
+	Content *v2.HtmlData `json:"-"`
+	Text    string       `json:"text"`
+}
+
+var (
+	unlikelyCandidates = regexp.MustCompile(`(?i)-ad-|ai2html|banner|breadcrumbs|combx|comment|community|cover-wrap|disqus|extra|` +
+		`footer|gdpr|header|legends|menu|related|remark|replies|rss|shoutbox|sidebar|skyscraper|social|sponsor|supplemental|` +
+		`ad-break|agegate|pagination|pager|popup|yom-remote|share|cookie|newsletter`)
+	maybeCandidate  = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow|chapter|reader`)
+	positiveHints   = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|post|text|blog|story|chapter|reader`)
+	negativeHints   = regexp.MustCompile(`(?i)-ad-|hidden|^hid$| hid$| hid |^hid |banner|combx|comment|com-|contact|foot|footer|footnote|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget|nav`)
+	titleSeparators = regexp.MustCompile(`\s+[|\-–—»·:]\s+`)
+	boilerplateTags = []string{"nav", "footer", "aside", "form", "iframe", "script", "style", "noscript", "button", "input", "select", "textarea", "svg", "object", "embed"}
+	paragraphTags   = []string{"p", "pre", "td", "blockquote", "li", "section", "div", "article"}
+)
+
+const excerptMaxLength = 300
+
+// Article finds the main content of a page by scoring blocks on their text length, commas, link density and class/id hints
+// the best block is returned together with its related siblings, cleaned of boilerplate
+func (a *Auto) Article(data *v2.HtmlData, baseLink string) *Article {
+	md := ExtractMetadata(data)
+	return a.article(data, baseLink, md, a.author(data, md).Value)
+}
+
+// article builds the Article from the metadata and author already found on the page
+func (a *Auto) article(data *v2.HtmlData, baseLink string, md *Metadata, author string) *Article {
+	content := mainContent(data)
+	article := &Article{
+		Title:   articleTitle(data, content, md),
+		Byline:  author,
+		Content: content,
+		Text:    content.InnerText(),
+	}
+	article.Excerpt = firstString(md.Description, excerpt(content))
+	article.LeadImage = md.Image
+	if article.LeadImage == "" {
+		if images := ExtractImages(content, baseLink); len(images) > 0 {
+			article.LeadImage = images[0].URL
+		}
+	} else if l, err := (&v2.HtmlData{Attributes: map[string]string{"src": article.LeadImage}}).FindLinks(baseLink, []string{"src"}); err == nil && l != "" {
+		article.LeadImage = l
+	}
+	return article
+}
+
+// mainContent returns a cleaned copy of the main content of the page, see Article
+func mainContent(data *v2.HtmlData) *v2.HtmlData {
+	body := data
+	if b := data.GetTags([]string{"body"}); len(b) > 0 {
+		body = b[0]
+	}
+	content := articleContent(topCandidate(body))
+	cleanArticle(content)
+	return content
+}
+
+// topCandidate scores every paragraph and gives the score to its ancestors, the highest scoring ancestor is the main content
+func topCandidate(body *v2.HtmlData) *v2.HtmlData {
+	scores := map[*v2.HtmlData]float64{}
+	var order []*v2.HtmlData
+	for _, p := range body.GetTags(paragraphTags) {
+		if isUnlikely(p) || hasBlockChildren(p) {
+			continue
+		}
+		text := p.Text()
+		if len(text) < 25 {
+			continue
+		}
+		score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text))/100, 3)
+		ancestor := p.Parent
+		for level := 0; ancestor != nil && level < 5; level++ {
+			if _, found := scores[ancestor]; !found {
+				scores[ancestor] = initialScore(ancestor)
+				order = append(order, ancestor)
+			}
+			switch level {
+			case 0:
+				scores[ancestor] += score
+			case 1:
+				scores[ancestor] += score / 2
+			default:
+				scores[ancestor] += score / float64(level*3)
+			}
+			if ancestor == body {
+				break
+			}
+			ancestor = ancestor.Parent
+		}
+	}
+	var top *v2.HtmlData
+	topScore := 0.0
+	for _, c := range order {
+		s := scores[c] * (1 - linkDensity(c))
+		scores[c] = s
+		if top == nil || s > topScore {
+			top, topScore = c, s
+		}
+	}
+	if top == nil {
+		return body
+	}
+	return top
+}
+
+// articleContent copies the top candidate together with siblings that look like part of the same content
+func articleContent(top *v2.HtmlData) *v2.HtmlData {
+	if top.Parent == nil {
+		return top.Clone()
+	}
+	topText := len(top.Text())
+	content := &v2.HtmlData{Tag: "div", Attributes: map[string]string{}}
+	for _, s := range top.Parent.Child {
+		include := s == top
+		if !include && !isUnlikely(s) {
+			text := s.Text()
+			density := linkDensity(s)
+			switch {
+			case strings.EqualFold(s.Tag, "p") && len(text) > 80 && density < 0.25:
+				include = true
+			case strings.EqualFold(s.Tag, "p") && len(text) > 0 && density == 0 && strings.ContainsAny(text, ".!?"):
+				include = true
+			case classWeight(s) > 0 && len(text) > topText/5 && density < 0.25:
+				include = true
+			}
+		}
+		if include {
+			c := s.Clone()
+			c.Parent = content
+			content.Child = append(content.Child, c)
+		}
+	}
+	return content
+}
+
+// cleanArticle removes boilerplate, link lists and empty elements from the content
+func cleanArticle(content *v2.HtmlData) {
+	content.Remove(func(d *v2.HtmlData) bool {
+		tag := strings.ToLower(d.Tag)
+		for _, b := range boilerplateTags {
+			if tag == b {
+				return true
+			}
+		}
+		if isUnlikely(d) || classWeight(d) < 0 && len(d.Text()) < 500 {
+			return true
+		}
+		if _, hidden := d.Attributes["hidden"]; hidden {
+			return true
+		}
+		switch tag {
+		case "ul", "ol", "div", "table", "section", "header":
+			text := d.Text()
+			if linkDensity(d) > 0.5 && len(text) < 500 {
+				return true
+			}
+			if text == "" && len(d.GetTags([]string{"img", "picture", "video"})) == 0 {
+				return true
+			}
+		case "p", "span":
+			if d.Text() == "" && len(d.GetTags([]string{"img", "picture"})) == 0 {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func articleTitle(data, content *v2.HtmlData, md *Metadata) string {
+	if md.Title != "" {
+		return md.Title
+	}
+	for _, h := range content.GetTags([]string{"h1"}) {
+		if t := h.Text(); t != "" {
+			return t
+		}
+	}
+	for _, t := range data.GetTags([]string{"title"}) {
+		title := t.Text()
+		// "Chapter Name | Site Name" keeps the longest part, which is usually the page title
+		parts := titleSeparators.Split(title, -1)
+		best := ""
+		for _, p := range parts {
+			if len(p) > len(best) {
+				best = p
+			}
+		}
+		if len(strings.Fields(best)) >= 2 {
+			return best
+		}
+		return title
+	}
+	return ""
+}
+
+// excerpt returns the first real paragraph of the content cut at a word near excerptMaxLength
+func excerpt(content *v2.HtmlData) string {
+	for _, p := range content.GetTags([]string{"p"}) {
+		text := p.Text()
+		if len(text) < 40 {
+			continue
+		}
+		if len(text) <= excerptMaxLength {
+			return text
+		}
+		cut := strings.LastIndex(text[:excerptMaxLength], " ")
+		if cut <= 0 {
+			// text without spaces, ie cjk, is cut at the last rune starting before the limit
+			cut = excerptMaxLength
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+		}
+		return strings.TrimSpace(text[:cut]) + "…"
+	}
+	return ""
+}
+
+func initialScore(d *v2.HtmlData) float64 {
+	score := classWeight(d)
+	switch strings.ToLower(d.Tag) {
+	case "div", "article", "main":
+		score += 5
+	case "pre", "td", "blockquote":
+		score += 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li", "form":
+		score -= 3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		score -= 5
+	}
+	return score
+}
+
+// classWeight scores the class and id of an element, content like names add 25 and boilerplate like names remove 25
+func classWeight(d *v2.HtmlData) float64 {
+	weight := 0.0
+	for _, v := range []string{d.Attributes["class"], d.Attributes["id"]} {
+		if v == "" {
+			continue
+		}
+		if negativeHints.MatchString(v) {
+			weight -= 25
+		}
+		if positiveHints.MatchString(v) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+func isUnlikely(d *v2.HtmlData) bool {
+	tag := strings.ToLower(d.Tag)
+	if tag == "body" || tag == "article" || tag == "main" || tag == "a" {
+		return false
+	}
+	if role := d.Attributes["role"]; role == "navigation" || role == "complementary" || role == "banner" || role == "contentinfo" {
+		return true
+	}
+	hints := d.Attributes["class"] + " " + d.Attributes["id"]
+	return unlikelyCandidates.MatchString(hints) && !maybeCandidate.MatchString(hints)
+}
+
+// hasBlockChildren is true for containers whose text is made of other blocks, those are scored through their children
+func hasBlockChildren(d *v2.HtmlData) bool {
+	for _, c := range d.Child {
+		switch strings.ToLower(c.Tag) {
+		case "p", "div", "section", "article", "table", "ul", "ol", "blockquote", "pre", "h1", "h2", "h3", "h4", "h5", "h6":
+			return true
+		}
+	}
+	return false
+}
+
+// linkDensity is the amount of the element's text that is inside links
+func linkDensity(d *v2.HtmlData) float64 {
+	text := len(d.Text())
+	if text == 0 {
+		return 0
+	}
+	links := 0
+	for _, a := range d.GetTags([]string{"a"}) {
+		links += len(a.Text())
+	}
+	return math.Min(float64(links)/float64(text), 1)
+}
@@ -0,0 +1,20 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+)
+
+func TestExcerptKeepsRunes(t *testing.T) {
+	page, err := v2.NewHTMLSourceRequest().ProcessSourceCode("<html><body><p>a" + strings.Repeat("中", 200) + "</p></body></html>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := excerpt(page)
+	if !utf8.ValidString(got) || !strings.HasSuffix(got, "中…") {
+		t.Errorf("excerpt = %q, want valid text cut between runes", got)
+	}
+}
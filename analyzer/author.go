@@ -0,0 +1,131 @@
+package analyzer
+
+import (
+	"regexp"
+	"strings"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+)
+
+var (
+	authorWords = `(?:authors?|author\(s\)|artists?|artist\(s\)|writers?|written by|story by|art by|illustrators?|` +
+		`creators?|mangaka|auteurs?|autor(?:es|a)?|autore|作者|著者|작가)`
+	// authorLabel is a label followed by a colon, or a label that is the whole text, so "Authority figures" is not one
+	authorLabel = regexp.MustCompile(`(?i)^\s*` + authorWords + `\s*(?:[:：]|$)\s*`)
+	// authorPrefix strips the label of an element known to be labelled, ie "Author Jane" when "Author" is its own text
+	authorPrefix = regexp.MustCompile(`(?i)^\s*` + authorWords + `(?:\s*[:：]|\s+|$)\s*`)
+	byline       = regexp.MustCompile(`^\s*(?:[Bb]y|BY|[Pp]ar|[Pp]or|[Vv]on)\s+`)
+	nextLabel    = regexp.MustCompile(`\s+\p{Lu}[\p{L} ]{1,20}[:：]`)
+	authorHints  = regexp.MustCompile(`(?i)author|byline|writer|artist|creator`)
+	authorSplit  = regexp.MustCompile(`\s*(?:[,;/&]|\s+and\s+)\s*`)
+)
+
+// authorsFromLinks returns the text of links marked with rel=author and the first of those links
+func authorsFromLinks(data *v2.HtmlData) ([]string, *v2.HtmlData) {
+	var output []string
+	var node *v2.HtmlData
+	for _, l := range data.Search([]string{"a", "link"}, map[string]string{"rel": `(?i)(^|\s)author(\s|$)`}, nil) {
+		if a := cleanAuthor(l.Text()); a != "" {
+			output = append(output, a)
+			if node == nil {
+				node = l
+			}
+		}
+	}
+	return uniqueStrings(output), node
+}
+
+// authorsFromLabels returns the values of labelled fields such as "Author: name" or <dt>Artist</dt><dd>name</dd>
+// and the label they were found next to
+func authorsFromLabels(data *v2.HtmlData) ([]string, *v2.HtmlData) {
+	var output []string
+	for _, n := range data.GetTags(nil) {
+		if n.TextData == "" || !authorLabel.MatchString(n.TextData) {
+			continue
+		}
+		text := n.Text()
+		if len(text) > 200 {
+			continue
+		}
+		value := authorPrefix.ReplaceAllString(text, "")
+		if value == "" && n.Parent != nil {
+			// the label is often its own element, the value then follows it inside the same parent
+			if pt := n.Parent.Text(); len(pt) <= 200 && authorLabel.MatchString(pt) {
+				value = authorLabel.ReplaceAllString(pt, "")
+			} else if next := nextElement(n); next != nil {
+				value = next.Text()
+			}
+		}
+		if loc := nextLabel.FindStringIndex(value); loc != nil {
+			value = value[:loc[0]]
+		}
+		output = append(output, splitAuthors(value)...)
+		if len(output) > 0 {
+			return uniqueStrings(output), n
+		}
+	}
+	return nil, nil
+}
+
+// authorsFromHints returns short "By name" bylines and elements with an author or byline class, id or itemprop
+// and the first element a name was found in
+func authorsFromHints(data *v2.HtmlData) ([]string, *v2.HtmlData) {
+	var output []string
+	var node *v2.HtmlData
+	for _, n := range data.GetTags(nil) {
+		hinted := authorHints.MatchString(n.Attributes["class"] + " " + n.Attributes["id"] + " " + n.Attributes["itemprop"])
+		if !hinted && !byline.MatchString(n.TextData) {
+			continue
+		}
+		text := n.Text()
+		if text == "" || len(text) > 80 {
+			continue
+		}
+		if !hinted && len(strings.Fields(text)) > 6 {
+			continue
+		}
+		text = byline.ReplaceAllString(authorLabel.ReplaceAllString(text, ""), "")
+		names := splitAuthors(text)
+		if len(names) > 0 && node == nil {
+			node = n
+		}
+		output = append(output, names...)
+	}
+	return uniqueStrings(output), node
+}
+
+// nextElement returns the element following n in its parent, for a dt or th it returns the matching dd or td
+func nextElement(n *v2.HtmlData) *v2.HtmlData {
+	if n.Parent == nil {
+		return nil
+	}
+	for i, c := range n.Parent.Child {
+		if c != n {
+			continue
+		}
+		if i+1 < len(n.Parent.Child) {
+			return n.Parent.Child[i+1]
+		}
+	}
+	return nil
+}
+
+// splitAuthors splits a list of names joined with commas, semicolons, slashes or "and"
+func splitAuthors(s string) []string {
+	var output []string
+	for _, part := range authorSplit.Split(s, -1) {
+		if a := cleanAuthor(part); a != "" {
+			output = append(output, a)
+		}
+	}
+	return output
+}
+
+func cleanAuthor(s string) string {
+	s = strings.Trim(strings.TrimSpace(s), ":：-|·•")
+	s = strings.TrimSpace(s)
+	if len(s) > 60 || strings.EqualFold(s, "unknown") || strings.EqualFold(s, "n/a") {
+		return ""
+	}
+	return s
+}
@@ -0,0 +1,28 @@
+package analyzer
+
+import (
+	"testing"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+)
+
+func TestAuthorLabels(t *testing.T) {
+	for _, c := range []struct {
+		body, want string
+	}{
+		{`<p>Authority figures said stuff</p>`, ""},
+		{`<p>Artistic direction was great</p><p>Writers' room notes</p>`, ""},
+		{`<p>Author: Jane Doe</p>`, "Jane Doe"},
+		{`<p>作者：尾田栄一郎</p>`, "尾田栄一郎"},
+		{`<div><span>Author</span><span>Jane Doe</span></div>`, "Jane Doe"},
+		{`<dl><dt>Artists</dt><dd>Jane Doe, John Roe</dd></dl>`, "Jane Doe, John Roe"},
+	} {
+		page, err := v2.NewHTMLSourceRequest().ProcessSourceCode("<html><body>" + c.body + "</body></html>")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := NewAuto().Author(page); got != c.want {
+			t.Errorf("Author(%s) = %q, want %q", c.body, got, c.want)
+		}
+	}
+}
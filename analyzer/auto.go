@@ -3,36 +3,95 @@ package analyzer
 import (
 	"regexp"
 	"strings"
+	"time"
 
 	v2 "github.com/Seann-Moser/WebParser/v2"
 )
 
 type Auto struct {
+	// Plugins are looked up with the url of the page to override the generic heuristics for known sites, nil disables plugins
+	Plugins *PluginRegistry
+	// Taxonomy maps the tags found onto canonical names, nil keeps the tags as they are written
+	Taxonomy *Taxonomy
+	// TagStopWords are dropped from the tags on top of DefaultTagStopWords
+	TagStopWords []string
+	// Now is the time relative dates like "3 days ago" are counted from, time.Now when nil
+	Now func() time.Time
+
+	site    Plugin
+	siteURL string
 }
 
 func NewAuto() *Auto {
-	return &Auto{}
+	return &Auto{Plugins: DefaultPlugins}
+}
+
+// ForURL returns a copy of Auto using the plugin of pageURL, this is needed for methods that are not given the url
+// of the page like Title, Description, Author and TagAuto
+func (a *Auto) ForURL(pageURL string) *Auto {
+	c := *a
+	c.site, c.siteURL = nil, pageURL
+	if a.Plugins != nil {
+		c.site = a.Plugins.Lookup(pageURL)
+	}
+	return &c
+}
+
+// plugin returns the plugin for baseLink, or the one set with ForURL when baseLink is empty, and the url to give it
+func (a *Auto) plugin(baseLink string) (Plugin, string) {
+	if baseLink == "" || baseLink == a.siteURL {
+		return a.site, a.siteURL
+	}
+	if a.Plugins == nil {
+		return nil, baseLink
+	}
+	return a.Plugins.Lookup(baseLink), baseLink
 }
 
 func (a *Auto) Title(data *v2.HtmlData) string {
-	v := data.Search([]string{}, map[string]string{"tag": "title"}, nil)
-	if len(v) == 0 {
-		return ""
+	return a.title(data, ExtractMetadata(data)).Value
+}
+
+func (a *Auto) title(data *v2.HtmlData, md *Metadata) *TextField {
+	if p, link := a.plugin(""); p != nil {
+		if tp, ok := p.(TitlePlugin); ok {
+			if v, ok := tp.Title(data, link); ok {
+				return &TextField{Value: v, Field: pluginField}
+			}
+		}
 	}
-	for i := 0; i < len(v); i++ {
-		current := v[i]
-		if current.TextData != "" {
-			return current.TextData
+	if md.Title != "" {
+		return &TextField{Value: md.Title, Field: metadataField(md, "title")}
+	}
+	for _, t := range data.Search([]string{}, map[string]string{"tag": "title"}, nil) {
+		if t.TextData != "" {
+			return &TextField{Value: t.TextData, Field: heuristic(0.8, t)}
+		}
+	}
+	for _, h := range data.GetTags([]string{"h1"}) {
+		if text := h.Text(); text != "" {
+			return &TextField{Value: text, Field: heuristic(0.6, h)}
 		}
 	}
-	return ""
+	return &TextField{}
 }
 
 func (a *Auto) Description(data *v2.HtmlData) string {
-	v := data.Search([]string{}, map[string]string{"text": "description", "text_2": "summary", "text_3": "Synopsis"}, nil)
-	if len(v) == 0 {
-		return ""
+	return a.description(data, ExtractMetadata(data)).Value
+}
+
+func (a *Auto) description(data *v2.HtmlData, md *Metadata) *TextField {
+	if p, link := a.plugin(""); p != nil {
+		if dp, ok := p.(DescriptionPlugin); ok {
+			if v, ok := dp.Description(data, link); ok {
+				return &TextField{Value: v, Field: pluginField}
+			}
+		}
+	}
+	if md.Description != "" {
+		return &TextField{Value: md.Description, Field: metadataField(md, "description")}
 	}
+	v := data.Search([]string{}, map[string]string{"text": "description", "text_2": "summary", "text_3": "Synopsis"}, nil)
 	skip := []string{}
 	for i := len(v) - 1; i >= 0; i-- {
 		current := v[i]
@@ -43,46 +102,158 @@ func (a *Auto) Description(data *v2.HtmlData) string {
 				current = current.Parent
 				continue
 			}
-			fd := (&v2.HtmlData{
-				ID:         "",
-				Parent:     nil,
-				Tag:        "",
-				Attributes: map[string]string{},
-				TextData:   "",
-				Child:      d,
-				Sibling:    nil,
-			}).Flatten(nil, skip)
-			return strings.ReplaceAll(strings.ReplaceAll(strings.ReplaceAll(fd.TextData, "---", " "), "\n", " "), "\t", " ")
+			return &TextField{Value: joinText(d, skip), Field: fuzzy(0.5, current)}
 		}
 	}
-	return ""
+	// the metadata has no description so the excerpt of the article is the first paragraph of its content
+	text := excerpt(mainContent(data))
+	if text == "" {
+		return &TextField{}
+	}
+	return &TextField{Value: text, Field: Field{Confidence: 0.4, Source: SourceHeuristic}}
 }
 
+// Author returns the authors of the page joined with a comma
+// metadata is used first, then rel=author links, labelled fields like "Author:" or "Artist:" and finally bylines
 func (a *Auto) Author(data *v2.HtmlData) string {
-	return ""
+	return a.author(data, ExtractMetadata(data)).Value
+}
+
+func (a *Auto) author(data *v2.HtmlData, md *Metadata) *TextField {
+	if p, link := a.plugin(""); p != nil {
+		if ap, ok := p.(AuthorPlugin); ok {
+			if v, ok := ap.Author(data, link); ok {
+				return &TextField{Value: v, Field: pluginField}
+			}
+		}
+	}
+	if len(md.Authors) > 0 {
+		return &TextField{Value: strings.Join(md.Authors, ", "), Field: metadataField(md, "authors")}
+	}
+	if authors, node := authorsFromLinks(data); len(authors) > 0 {
+		return &TextField{Value: strings.Join(authors, ", "), Field: heuristic(0.8, node)}
+	}
+	if authors, node := authorsFromLabels(data); len(authors) > 0 {
+		return &TextField{Value: strings.Join(authors, ", "), Field: heuristic(0.7, node)}
+	}
+	if authors, node := authorsFromHints(data); len(authors) > 0 {
+		return &TextField{Value: strings.Join(authors, ", "), Field: heuristic(0.45, node)}
+	}
+	return &TextField{}
 }
 
+// TagAuto returns the cleaned and deduplicated tags of a page, see NormalizeTags
+// genres and keywords from the metadata are joined with the tags listed next to a tag or genre label
 func (a *Auto) TagAuto(data *v2.HtmlData) []string {
-	for i := 1; i <= 3; i++ {
-		tags := a.Tags(getData(data), i)
-		if len(tags) != 0 {
-			return tags
+	return a.tagField(data, ExtractMetadata(data)).Value
+}
+
+func (a *Auto) tagField(data *v2.HtmlData, md *Metadata) *ListField {
+	if p, link := a.plugin(""); p != nil {
+		if tp, ok := p.(TagsPlugin); ok {
+			if v, ok := tp.Tags(data, link); ok {
+				return &ListField{Value: a.NormalizeTags(v), Field: pluginField}
+			}
 		}
 	}
-	return nil
+	var listed []string
+	var node *v2.HtmlData
+	for i := 1; i <= 3 && len(listed) == 0; i++ {
+		listed, node = a.tags(getData(data), i)
+	}
+	output := &ListField{Value: a.NormalizeTags(append(append(append([]string{}, md.Genres...), md.Keywords...), listed...))}
+	switch {
+	case len(output.Value) == 0:
+	case len(md.Genres) > 0:
+		output.Field = metadataField(md, "genres")
+	case len(md.Keywords) > 0:
+		output.Field = metadataField(md, "keywords")
+	default:
+		output.Field = fuzzy(listConfidence(0.4, 0.55, len(output.Value)), node)
+	}
+	return output
+}
+
+// joinText returns the readable text of the nodes on a single line
+// nodes nested inside another node of the list are only counted once and skipped nodes are left out at any depth
+// like Flatten does, so a label inside the description is not part of its text
+func joinText(nodes []*v2.HtmlData, skipId []string) string {
+	set := map[*v2.HtmlData]struct{}{}
+	for _, n := range nodes {
+		set[n] = struct{}{}
+	}
+	var text []string
+	for _, n := range nodes {
+		if isSkipped(n.ID, skipId) || hasAncestor(n, set) {
+			continue
+		}
+		if hasSkipped(n, skipId) {
+			// the copy keeps the ids so the skipped nodes can be removed from it
+			n = n.Clone()
+			n.Remove(func(d *v2.HtmlData) bool {
+				return isSkipped(d.ID, skipId)
+			})
+		}
+		if t := n.Text(); t != "" {
+			text = append(text, t)
+		}
+	}
+	return strings.Join(text, " ")
+}
+
+// hasSkipped is true when a node inside n is skipped
+func hasSkipped(n *v2.HtmlData, skipId []string) bool {
+	for _, d := range n.Elements()[1:] {
+		if isSkipped(d.ID, skipId) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAncestor(n *v2.HtmlData, set map[*v2.HtmlData]struct{}) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if _, found := set[p]; found {
+			return true
+		}
+	}
+	return false
 }
+
+func isSkipped(id string, skipId []string) bool {
+	if id == "" {
+		return false
+	}
+	for _, s := range skipId {
+		if s == id {
+			return true
+		}
+	}
+	return false
+}
+
 func getData(data *v2.HtmlData) *v2.HtmlData {
 	tmp := *data
 	return &tmp
 }
 func (a *Auto) Tags(data *v2.HtmlData, parents int) []string {
+	tags, _ := a.tags(data, parents)
+	return tags
+}
+
+// tags returns the tags and the element they were listed in
+func (a *Auto) tags(data *v2.HtmlData, parents int) ([]string, *v2.HtmlData) {
 	v := data.Search([]string{}, map[string]string{"text": "genre", "text_2": "tags", "text_3": "genres", "*": "tag"}, nil)
 	if len(v) == 0 {
-		return nil
+		return nil, nil
 	}
 	skip := []string{}
 	for i := 0; i < len(v); i++ {
 		current := v[i]
+		if inNavigation(current) {
+			// a genre link of the menu is not the label of the tag list
+			continue
+		}
 		for j := 0; j < parents; j++ {
 			if current.Tag == "head" || current.Tag == "meta" {
 				break
@@ -97,7 +268,7 @@ func (a *Auto) Tags(data *v2.HtmlData, parents int) []string {
 			dup := map[string]struct{}{}
 			numReg, _ := regexp.Compile("^[0-9]+")
 			for _, s := range d {
-				if len(s.TextData) == 0 {
+				if len(s.TextData) == 0 || inNavigation(s) {
 					continue
 				}
 				if _, found := dup[s.TextData]; found {
@@ -109,111 +280,190 @@ func (a *Auto) Tags(data *v2.HtmlData, parents int) []string {
 				output = append(output, s.TextData)
 				dup[s.TextData] = struct{}{}
 			}
-			return output
+			if len(output) == 0 {
+				current = current.Parent
+				continue
+			}
+			return output, current
 		}
 	}
-	return nil
+	return nil, nil
 }
 
-func (a *Auto) Dates(data *v2.HtmlData) []string {
-	return nil
+// Dates returns the dates found on the page, <time datetime> and published/modified metadata come first
+// followed by dates written in the text of the page in the order they appear
+func (a *Auto) Dates(data *v2.HtmlData) []time.Time {
+	return a.dates(data, ExtractMetadata(data)).Value
+}
+
+// dates reports the confidence and path of the most trusted date found, metadata then <time>, elements with a date class
+// and then the text
+func (a *Auto) dates(data *v2.HtmlData, md *Metadata) *DateField {
+	now := time.Now()
+	if a.Now != nil {
+		now = a.Now()
+	}
+	output := &DateField{}
+	add := func(t time.Time, f Field) {
+		for _, o := range output.Value {
+			if o.Equal(t) {
+				return
+			}
+		}
+		if len(output.Value) == 0 {
+			output.Field = f
+		}
+		output.Value = append(output.Value, t)
+	}
+	for _, field := range []string{"published", "modified"} {
+		s := md.Published
+		if field == "modified" {
+			s = md.Modified
+		}
+		if t, ok := ParseDate(s, now); ok {
+			add(t, metadataField(md, field))
+		}
+	}
+	for _, t := range data.GetTags([]string{"time"}) {
+		value := t.Attributes["datetime"]
+		confidence := 0.75
+		if value == "" {
+			value = t.Text()
+			confidence = 0.6
+		}
+		if d, ok := ParseDate(value, now); ok {
+			add(d, heuristic(confidence, t))
+		}
+	}
+	for _, n := range data.GetTags(nil) {
+		if strings.EqualFold(n.Tag, "time") || !dateElement(n) {
+			continue
+		}
+		if d, ok := ParseDate(n.Text(), now); ok {
+			add(d, heuristic(0.5, n))
+		}
+	}
+	body := data
+	if b := data.GetTags([]string{"body"}); len(b) > 0 {
+		body = b[0]
+	}
+	for _, t := range FindDates(bodyText(data), now) {
+		add(t, fuzzy(0.4, body))
+	}
+	return output
 }
 
+// Language returns the languages of the page as normalized tags, ie en-US
+// declared languages from <html lang>, Content-Language and meta tags come first, the language detected from the text is added
+// when it is not already in the list
 func (a *Auto) Language(data *v2.HtmlData) []string {
-	return nil
+	return a.language(data, ExtractMetadata(data)).Value
 }
 
-func (a *Auto) Chapters(data *v2.HtmlData, baseLink string) []*v2.HtmlData {
-	v := data.Search(nil, map[string]string{"text": "chapter", "class": "chapter", "*": "thumbnail"}, nil)
-	if len(v) == 0 {
-		return nil
+func (a *Auto) language(data *v2.HtmlData, md *Metadata) *ListField {
+	type declaration struct {
+		tag   string
+		field Field
 	}
-	skip := []string{}
-	for i := 0; i < len(v); i++ {
-		current := v[i]
-		for j := 0; j < 2; j++ {
-			if current.Tag == "head" || current.Tag == "meta" {
-				break
-			}
-			skip = append(skip, current.ID)
+	var declared []declaration
+	for _, h := range data.GetTags([]string{"html"}) {
+		for _, attr := range []string{"lang", "xml:lang"} {
+			declared = append(declared, declaration{h.Attributes[attr], Field{Confidence: 0.9, Source: SourceMeta, Path: h.Path}})
+		}
+	}
+	metaField := func(key string) Field {
+		return Field{Confidence: 0.85, Source: SourceMeta, Path: nodePath(md.metaNodes[key])}
+	}
+	for _, l := range splitList(md.Meta["content-language"]) {
+		declared = append(declared, declaration{l, metaField("content-language")})
+	}
+	declared = append(declared, declaration{md.Meta["language"], metaField("language")},
+		declaration{firstValue(md.OpenGraph["og:locale"]), metaField("og:locale")}, declaration{md.Language, metadataField(md, "language")})
 
-			links := current.Search([]string{"a"}, map[string]string{"href": "^/.*[0-9]+", "src": "^/.*[0-9]+"}, skip)
-			if len(links) == 0 {
-				current = current.Parent
-				continue
-			}
-			for _, link := range links {
-				link.AddLinkInfo(baseLink, []string{"href", "src"})
+	output := &ListField{}
+	primary := map[string]struct{}{}
+	add := func(tag string, f Field) {
+		tag = normalizeLanguage(tag)
+		if tag == "" {
+			return
+		}
+		p := strings.Split(tag, "-")[0]
+		for _, o := range output.Value {
+			if o == tag {
+				return
 			}
-			return links
-			//return strings.ReplaceAll(strings.ReplaceAll(strings.ReplaceAll(fd.TextData, "---", " "), "\n", " "), "\t", " ")
+		}
+		if len(output.Value) == 0 {
+			output.Field = f
+		}
+		primary[p] = struct{}{}
+		output.Value = append(output.Value, tag)
+	}
+	for _, d := range declared {
+		add(d.tag, d.field)
+	}
+	if detected := DetectLanguage(bodyText(data)); detected != "" {
+		if _, found := primary[detected]; !found {
+			add(detected, Field{Confidence: 0.5, Source: SourceHeuristic})
 		}
 	}
-	return nil
+	return output
 }
 
-func (a *Auto) Images(data *v2.HtmlData, baseLink string) []string {
-	v := data.Search([]string{"script"}, map[string]string{"text": "jpg", "text_1": "jpeg", "text_2": "png"}, nil)
-	if len(v) > 0 {
-		urlRegex, err := regexp.Compile("((http|https)://)(www.)?[a-zA-Z0-9@:%._\\+~#?&//=]{2,256}\\.[a-z]{2,6}\\b([-a-zA-Z0-9@:%._\\+~#?&//=]*)")
-		if err != nil {
-			return nil
-		}
-		reg, err := regexp.Compile("[, \n\"]")
-		if err != nil {
-			return nil
-		}
-		var output []string
-		splitStrings := reg.Split(v[0].TextData, -1)
-		for _, s := range splitStrings {
-			if len(strings.TrimSpace(s)) == 0 {
-				continue
-			}
-			s = strings.ReplaceAll(s, "\\/", "/")
-			if urlRegex.MatchString(s) {
-				output = append(output, s)
-			}
-		}
-		return output
+// bodyText returns the readable text of the <body>, or of the whole page when it has no body
+func bodyText(data *v2.HtmlData) string {
+	if body := data.GetTags([]string{"body"}); len(body) > 0 {
+		return body[0].InnerText()
 	}
+	return data.InnerText()
+}
 
-	v = data.Search([]string{"img", "a", "data-src"}, map[string]string{"*": "jpg", "*_1": "jpeg", "*_2": "png"}, nil)
-	if len(v) == 0 {
-		return nil
+// Images returns the urls of the content images of a page in reading order, see ExtractImages
+// an ImagesPlugin matching baseLink is used first
+func (a *Auto) Images(data *v2.HtmlData, baseLink string) []string {
+	var output []string
+	images, _ := a.images(data, baseLink)
+	for _, img := range images {
+		output = append(output, img.URL)
 	}
-	skip := []string{}
-	output := []string{}
-	for i := 0; i < len(v); i++ {
-		current := v[i]
-		for j := 0; j < 2; j++ {
-			if current.Tag == "head" || current.Tag == "meta" {
-				break
-			}
+	return output
+}
 
-			links := current.Search([]string{"a", "img"}, map[string]string{"href": "jpg|png|jpeg", "src": "jpg|png|jpeg", "data-src": "jpg|png|jpeg"}, skip)
-			skip = append(skip, current.ID)
-			if len(links) == 0 {
-				current = current.Parent
-				continue
+// images also reports whether the images came from a plugin
+func (a *Auto) images(data *v2.HtmlData, baseLink string) ([]*Image, bool) {
+	if p, link := a.plugin(baseLink); p != nil {
+		if ip, ok := p.(ImagesPlugin); ok {
+			if images, ok := ip.Images(data, link); ok {
+				return images, true
 			}
+		}
+	}
+	return ExtractImages(data, baseLink), false
+}
 
-			for _, link := range links {
-				link.AddLinkInfo(baseLink, []string{"href", "src", "data-src"})
-				l, err := link.FindLinks(baseLink, []string{"href", "data-src", "src"})
-				if err != nil || l == "" {
-					continue
-				}
-				output = append(output, l)
+// Pages returns the links to the other pages of a page, links near anything named page are used first
+// and the numbered links found by DetectPagination when there are none, a PagesPlugin matching baseLink is used before both
+func (a *Auto) Pages(data *v2.HtmlData, baseLink string) []string {
+	pages, _ := a.pages(data, baseLink)
+	return pages
+}
+
+// pages also reports whether the pages came from a plugin
+func (a *Auto) pages(data *v2.HtmlData, baseLink string) ([]string, bool) {
+	if p, link := a.plugin(baseLink); p != nil {
+		if pp, ok := p.(PagesPlugin); ok {
+			if pages, ok := pp.Pages(data, link); ok {
+				return pages, true
 			}
 		}
 	}
-	return output
+	return findPages(data, baseLink), false
 }
 
-func (a *Auto) Pages(data *v2.HtmlData, baseLink string) []string {
+func findPages(data *v2.HtmlData, baseLink string) []string {
 	v := data.Search([]string{}, map[string]string{"*": "page"}, nil)
 	if len(v) == 0 {
-		return nil
+		return DetectPagination(data, baseLink).Pages
 	}
 	skip := []string{}
 	output := []string{}
@@ -249,5 +499,44 @@ func (a *Auto) Pages(data *v2.HtmlData, baseLink string) []string {
 			}
 		}
 	}
+	if len(output) == 0 {
+		return DetectPagination(data, baseLink).Pages
+	}
 	return output
 }
+
+func (a *Auto) chapterField(data *v2.HtmlData, baseLink string) *ChapterField {
+	list, fromPlugin := a.chapters(data, baseLink)
+	output := &ChapterField{Value: list.Chapters, Descending: list.Descending}
+	switch {
+	case fromPlugin:
+		output.Field = pluginField
+	case len(list.Chapters) > 0:
+		output.Field = heuristic(listConfidence(0.5, 0.8, len(list.Chapters)), list.Container)
+	}
+	return output
+}
+
+func (a *Auto) imageField(data *v2.HtmlData, baseLink string) *ImageField {
+	images, fromPlugin := a.images(data, baseLink)
+	switch {
+	case fromPlugin:
+		return &ImageField{Value: images, Field: pluginField}
+	case len(images) == 0:
+		return &ImageField{}
+	case images[0].Source == "script":
+		return &ImageField{Value: images, Field: fuzzy(listConfidence(0.4, 0.6, len(images)), images[0].node)}
+	}
+	return &ImageField{Value: images, Field: heuristic(listConfidence(0.5, 0.8, len(images)), images[0].node.Parent)}
+}
+
+func (a *Auto) pageField(data *v2.HtmlData, baseLink string) *ListField {
+	pages, fromPlugin := a.pages(data, baseLink)
+	switch {
+	case fromPlugin:
+		return &ListField{Value: pages, Field: pluginField}
+	case len(pages) == 0:
+		return &ListField{}
+	}
+	return &ListField{Value: pages, Field: Field{Confidence: listConfidence(0.35, 0.5, len(pages)), Source: SourceFuzzyText}}
+}
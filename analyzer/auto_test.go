@@ -0,0 +1,22 @@
+package analyzer
+
+import (
+	"testing"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+)
+
+func TestJoinTextSkipsNestedLabels(t *testing.T) {
+	page, err := v2.NewHTMLSourceRequest().ProcessSourceCode(`<div><p><b>Synopsis:</b> The crew sets sail.</p><span>A new island.</span></div>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	label := page.GetTags([]string{"b"})[0]
+	nodes := page.GetTags([]string{"p", "span"})
+	if got, want := joinText(nodes, []string{label.ID}), "The crew sets sail. A new island."; got != want {
+		t.Errorf("joinText = %q, want %q", got, want)
+	}
+	if got := page.Text(); got != "Synopsis: The crew sets sail. A new island." {
+		t.Errorf("the page was changed: %q", got)
+	}
+}
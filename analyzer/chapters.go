@@ -0,0 +1,292 @@
+package analyzer
+
+import (
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+	"github.com/Seann-Moser/WebParser/website"
+)
+
+// Chapter is a single entry of a chapter list
+type Chapter struct {
+	Number float64 `json:"number"`
+	// Volume is 0 when the chapter does not name one
+	Volume float64   `json:"volume"`
+	Title  string    `json:"title"`
+	URL    string    `json:"url"`
+	Date   time.Time `json:"date"`
+}
+
+// ChapterList is the chapters of a page sorted in ascending order
+type ChapterList struct {
+	Chapters []*Chapter `json:"chapters"`
+	// Descending is true when the page lists the newest chapter first
+	Descending bool `json:"descending"`
+	// Container is the element the list was found in
+	Container *v2.HtmlData `json:"-"`
+}
+
+var (
+	chapterText = regexp.MustCompile(`(?i)(?:\b(?:vol(?:ume)?|tome|band)\.?\s*(\d+(?:\.\d+)?)\s*[,:\-–]?\s*)?` +
+		`\b(?:chapters?|chap|ch|episodes?|ep|capítulo|capitulo|cap|chapitre|kapitel|#)\.?\s*(\d+(?:\.\d+)?)`)
+	chapterCJK      = regexp.MustCompile(`(?:第\s*(\d+(?:\.\d+)?)\s*[卷巻]\s*)?第\s*(\d+(?:\.\d+)?)\s*[话話章回集]`)
+	chapterHref     = regexp.MustCompile(`(?i)(?:^|[^a-z])(?:chapter|chap|ch|episode|ep|c)[-_/.]?(\d+(?:[._]\d+|-\d+(?:$|/))?)(?:[-_/.?#]|$)`)
+	volumeHref      = regexp.MustCompile(`(?i)(?:^|[^a-z])(?:volume|vol|v)[-_/.]?(\d+)(?:[-_/.]|$)`)
+	bareNumber      = regexp.MustCompile(`^\s*(\d+(?:\.\d+)?)\s*$`)
+	prologue        = regexp.MustCompile(`(?i)^\s*(prologue|prólogo|prologo|序章)\b`)
+	chapterShortcut = regexp.MustCompile(`(?i)\b(first|latest|last|newest|new|next|prev|previous|continue|start|begin|read now|read first|read last)\b`)
+)
+
+// Chapters finds the chapter list of a page, chapter numbers are read from the link text ("Vol. 2 Ch. 3", "Chapter 10.5", 第12话)
+// or the url, shortcut links like "First Chapter" or "Latest" are ignored, duplicate urls are removed and the list is sorted ascending
+// a ChaptersPlugin matching baseLink is used first
+func (a *Auto) Chapters(data *v2.HtmlData, baseLink string) *ChapterList {
+	list, _ := a.chapters(data, baseLink)
+	return list
+}
+
+// chapters also reports whether the list came from a plugin
+func (a *Auto) chapters(data *v2.HtmlData, baseLink string) (*ChapterList, bool) {
+	if p, link := a.plugin(baseLink); p != nil {
+		if cp, ok := p.(ChaptersPlugin); ok {
+			if list, ok := cp.Chapters(data, link); ok {
+				return list, true
+			}
+		}
+	}
+	return findChapters(data, baseLink), false
+}
+
+func findChapters(data *v2.HtmlData, baseLink string) *ChapterList {
+	base, _ := url.Parse(baseLink)
+	type entry struct {
+		chapter *Chapter
+		node    *v2.HtmlData
+	}
+	var entries []*entry
+	counts := map[*v2.HtmlData]int{}
+	for _, link := range data.GetTags([]string{"a"}) {
+		href := strings.TrimSpace(link.Attributes["href"])
+		if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+			continue
+		}
+		text := link.Text()
+		if text == "" {
+			text = link.Attributes["title"]
+		}
+		if chapterShortcut.MatchString(text) {
+			continue
+		}
+		c, ok := parseChapter(text, href)
+		if !ok {
+			continue
+		}
+		c.URL = website.NormalizeURL(resolveURL(base, href))
+		entries = append(entries, &entry{chapter: c, node: link})
+		for p := link.Parent; p != nil; p = p.Parent {
+			counts[p]++
+		}
+	}
+	if len(entries) == 0 {
+		return &ChapterList{}
+	}
+
+	// every link belongs to its closest ancestor holding another chapter link, the biggest of those clusters is the list
+	// clusters that look like the biggest one are kept too since sites often split the list per volume
+	clusters := map[*v2.HtmlData]int{}
+	containers := make([]*v2.HtmlData, len(entries))
+	var best *v2.HtmlData
+	for i, e := range entries {
+		container := e.node.Parent
+		for container != nil && counts[container] < 2 && container.Parent != nil {
+			container = container.Parent
+		}
+		containers[i] = container
+		clusters[container]++
+		if best == nil || clusters[container] > clusters[best] {
+			best = container
+		}
+	}
+	list := &ChapterList{Container: best}
+	seen := map[string]struct{}{}
+	var ordered []*entry
+	for i, e := range entries {
+		container := containers[i]
+		if container != best && !similarContainer(container, best) {
+			continue
+		}
+		if _, found := seen[e.chapter.URL]; found {
+			continue
+		}
+		seen[e.chapter.URL] = struct{}{}
+		e.chapter.Date = chapterDate(e.node, container)
+		ordered = append(ordered, e)
+	}
+
+	chapters := make([]*Chapter, 0, len(ordered))
+	for _, e := range ordered {
+		chapters = append(chapters, e.chapter)
+	}
+	list.Chapters, list.Descending = sortChapters(chapters)
+	return list
+}
+
+// sortChapters sorts chapters listed in page order ascending and reports whether the page listed them newest first
+func sortChapters(chapters []*Chapter) ([]*Chapter, bool) {
+	up, down := 0, 0
+	for i := 1; i < len(chapters); i++ {
+		switch {
+		case chapterLess(chapters[i-1], chapters[i]):
+			up++
+		case chapterLess(chapters[i], chapters[i-1]):
+			down++
+		}
+	}
+	sort.SliceStable(chapters, func(i, j int) bool {
+		return chapterLess(chapters[i], chapters[j])
+	})
+	return chapters, down > up
+}
+
+// parseChapter reads the volume, number and title of a chapter link from its text, falling back to its url
+func parseChapter(text, href string) (*Chapter, bool) {
+	text = strings.TrimSpace(text)
+	c := &Chapter{Title: text}
+	if m := chapterText.FindStringSubmatchIndex(text); m != nil {
+		c.Volume = parseNumber(submatch(text, m, 1))
+		c.Number = parseNumber(submatch(text, m, 2))
+		c.Title = chapterTitle(text, m[0], m[1])
+		return c, true
+	}
+	if m := chapterCJK.FindStringSubmatchIndex(text); m != nil {
+		c.Volume = parseNumber(submatch(text, m, 1))
+		c.Number = parseNumber(submatch(text, m, 2))
+		c.Title = chapterTitle(text, m[0], m[1])
+		return c, true
+	}
+	if prologue.MatchString(text) {
+		return c, true
+	}
+	path := href
+	if u, err := url.Parse(href); err == nil {
+		path = u.Path
+		if u.RawQuery != "" {
+			path += "?" + u.RawQuery
+		}
+	}
+	m := chapterHref.FindStringSubmatch(path)
+	if m == nil {
+		return nil, false
+	}
+	n := parseNumber(strings.NewReplacer("_", ".", "-", ".").Replace(strings.TrimSuffix(m[1], "/")))
+	if bare := bareNumber.FindStringSubmatch(text); bare != nil {
+		n = parseNumber(bare[1])
+	}
+	c.Number = n
+	if v := volumeHref.FindStringSubmatch(path); v != nil {
+		c.Volume = parseNumber(v[1])
+	}
+	return c, true
+}
+
+// chapterTitle returns the text around the chapter number, "Ch. 3 - The Storm" gives "The Storm"
+// the whole text is kept when there is nothing else
+func chapterTitle(text string, start, end int) string {
+	rest := strings.TrimSpace(text[:start] + " " + text[end:])
+	rest = strings.TrimSpace(strings.Trim(rest, ":：-–—|·.,"))
+	if rest == "" {
+		return text
+	}
+	return rest
+}
+
+func submatch(s string, m []int, group int) string {
+	if m[2*group] < 0 {
+		return ""
+	}
+	return s[m[2*group]:m[2*group+1]]
+}
+
+func parseNumber(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(n, 0) {
+		return 0
+	}
+	return n
+}
+
+// chapterLess orders by volume then number, chapters without a volume are compared by number only
+func chapterLess(a, b *Chapter) bool {
+	if a.Volume != 0 && b.Volume != 0 && a.Volume != b.Volume {
+		return a.Volume < b.Volume
+	}
+	return a.Number < b.Number
+}
+
+// similarContainer is true for lists split in several elements with the same tag and class, ie one <ul class="chapters"> per volume
+func similarContainer(a, b *v2.HtmlData) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	return strings.EqualFold(a.Tag, b.Tag) && a.Attributes["class"] != "" && a.Attributes["class"] == b.Attributes["class"]
+}
+
+// chapterDate returns the date written next to the link inside its row of the list
+func chapterDate(link, container *v2.HtmlData) time.Time {
+	row := link
+	for row.Parent != nil && row.Parent != container {
+		row = row.Parent
+	}
+	now := time.Now()
+	for _, t := range row.GetTags([]string{"time"}) {
+		value := t.Attributes["datetime"]
+		if value == "" {
+			value = t.Text()
+		}
+		if d, ok := ParseDate(value, now); ok {
+			return d
+		}
+	}
+	for _, n := range row.GetTags(nil) {
+		for _, attr := range []string{"data-date", "datetime"} {
+			if d, ok := ParseDate(n.Attributes[attr], now); ok {
+				return d
+			}
+		}
+	}
+	for _, n := range row.GetTags(nil) {
+		if !dateElement(n) {
+			continue
+		}
+		if d, ok := ParseDate(n.Text(), now); ok {
+			return d
+		}
+	}
+	// the date is usually its own element, reading each one keeps it apart from the text around it
+	for _, n := range row.GetTags(nil) {
+		if d := FindDates(n.TextData, now); len(d) > 0 {
+			return d[0]
+		}
+	}
+	return time.Time{}
+}
+
+func resolveURL(base *url.URL, href string) string {
+	u, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	if base == nil {
+		return u.String()
+	}
+	return base.ResolveReference(u).String()
+}
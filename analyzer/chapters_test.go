@@ -0,0 +1,39 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+)
+
+func chapterNumbers(t *testing.T, body string) []float64 {
+	t.Helper()
+	page, err := v2.NewHTMLSourceRequest().ProcessSourceCode("<html><body>" + body + "</body></html>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var numbers []float64
+	if list := NewAuto().Chapters(page, "https://example.com/"); list != nil {
+		for _, c := range list.Chapters {
+			numbers = append(numbers, c.Number)
+		}
+	}
+	return numbers
+}
+
+func TestChaptersFromURLs(t *testing.T) {
+	got := chapterNumbers(t, `<ul><li><a href="/manga/x/chapter-1">Read</a></li><li><a href="/read/x/c2">Read</a></li>`+
+		`<li><a href="/series/x/ch_3">Read</a></li><li><a href="/x/v2/c4">Read</a></li></ul>`)
+	if want := []float64{1, 2, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("chapters = %v, want %v", got, want)
+	}
+}
+
+func TestChaptersSkipUnrelatedNumbers(t *testing.T) {
+	got := chapterNumbers(t, `<ul><li><a href="/forum/topic/7">Rules</a></li><li><a href="/topic/42">Help</a></li>`+
+		`<li><a href="/comic/123">Comic</a></li><li><a href="/search/5">Search</a></li></ul>`)
+	if len(got) != 0 {
+		t.Errorf("chapters = %v, want none", got)
+	}
+}
@@ -0,0 +1,276 @@
+package analyzer
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+)
+
+// monthNames maps month names and abbreviations in english, french, spanish, german, portuguese, italian and dutch
+var monthNames = map[string]time.Month{
+	"january": time.January, "jan": time.January, "janvier": time.January, "janv": time.January, "enero": time.January,
+	"ene": time.January, "januar": time.January, "jänner": time.January, "janeiro": time.January, "gennaio": time.January,
+	"gen": time.January, "januari": time.January,
+	"february": time.February, "feb": time.February, "février": time.February, "fevrier": time.February, "févr": time.February,
+	"febrero": time.February, "februar": time.February, "fevereiro": time.February, "fev": time.February, "febbraio": time.February,
+	"februari": time.February,
+	"march":    time.March, "mar": time.March, "mars": time.March, "marzo": time.March, "märz": time.March, "maerz": time.March,
+	"mär": time.March, "março": time.March, "marco": time.March, "maart": time.March, "mrt": time.March,
+	"april": time.April, "apr": time.April, "avril": time.April, "avr": time.April, "abril": time.April, "abr": time.April,
+	"aprile": time.April,
+	"may":    time.May, "mai": time.May, "mayo": time.May, "maio": time.May, "maggio": time.May, "mag": time.May, "mei": time.May,
+	"june": time.June, "jun": time.June, "juin": time.June, "junio": time.June, "juni": time.June, "junho": time.June,
+	"giugno": time.June, "giu": time.June,
+	"july": time.July, "jul": time.July, "juillet": time.July, "juil": time.July, "julio": time.July, "juli": time.July,
+	"julho": time.July, "luglio": time.July, "lug": time.July,
+	"august": time.August, "aug": time.August, "août": time.August, "aout": time.August, "agosto": time.August,
+	"ago": time.August, "augustus": time.August,
+	"september": time.September, "sep": time.September, "sept": time.September, "septembre": time.September,
+	"septiembre": time.September, "setiembre": time.September, "setembro": time.September, "set": time.September,
+	"settembre": time.September,
+	"october":   time.October, "oct": time.October, "octobre": time.October, "octubre": time.October, "oktober": time.October,
+	"okt": time.October, "outubro": time.October, "out": time.October, "ottobre": time.October, "ott": time.October,
+	"november": time.November, "nov": time.November, "novembre": time.November, "noviembre": time.November,
+	"novembro": time.November,
+	"december": time.December, "dec": time.December, "décembre": time.December, "decembre": time.December,
+	"déc": time.December, "diciembre": time.December, "dic": time.December, "dezember": time.December, "dez": time.December,
+	"dezembro": time.December, "dicembre": time.December,
+}
+
+var (
+	isoLayouts = []string{
+		time.RFC3339Nano, time.RFC3339, "2006-01-02T15:04:05Z0700", "2006-01-02T15:04:05", "2006-01-02T15:04",
+		"2006-01-02 15:04:05Z07:00", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02",
+		time.RFC1123Z, time.RFC1123, time.RFC850, time.ANSIC, time.UnixDate, "Mon, 2 Jan 2006 15:04:05 -0700",
+		"Mon, 2 Jan 2006 15:04:05 MST",
+	}
+	monthPattern   = monthRegex()
+	datePatterns   []*regexp.Regexp
+	numericDate    = regexp.MustCompile(`^(\d{4}|\d{1,2})[/.\-](\d{1,2})[/.\-](\d{4}|\d{1,2})$`)
+	cjkDate        = regexp.MustCompile(`(\d{4})\s*[年년]\s*(\d{1,2})\s*[月월]\s*(\d{1,2})\s*[日일]?`)
+	relativeDate   = regexp.MustCompile(`(?i)^(\d+|an?|one)\s+(second|sec|minute|min|hour|hr|day|week|month|year)s?\s+ago$`)
+	clockTime      = regexp.MustCompile(`(\d{1,2}):(\d{2})(?::(\d{2}))?\s*(am|pm)?`)
+	ordinalSuffix  = regexp.MustCompile(`(?i)(\d+)(st|nd|rd|th|er|º|ª)\b`)
+	dateWordSplits = regexp.MustCompile(`[\s,.]+`)
+	// dateLabel is the label written before a date, ie "Posted on" or "Updated:"
+	dateLabel = regexp.MustCompile(`(?i)^(?:posted|updated|published|released|added)(?:\s+on)?\s*:?\s*`)
+	// dateHint finds elements holding a date by their class, id or itemprop, ie "post-date" or "datePublished"
+	dateHint = regexp.MustCompile(`(?i)(?:^|[\s_\-])(?:date|time|posted|updated|published|released)`)
+)
+
+func init() {
+	datePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`\d{4}-\d{2}-\d{2}(?:[T ]\d{2}:\d{2}(?::\d{2}(?:\.\d+)?)?(?:Z|[+\-]\d{2}:?\d{2})?)?`),
+		regexp.MustCompile(`\b(?:\d{4}[/.]\d{1,2}[/.]\d{1,2}|\d{1,2}[/.\-]\d{1,2}[/.\-](?:\d{4}|\d{2}))\b`),
+		regexp.MustCompile(`\d{4}\s*[年년]\s*\d{1,2}\s*[月월]\s*\d{1,2}\s*[日일]?`),
+		regexp.MustCompile(`(?i)\b` + monthPattern + `\.?\s+\d{1,2}(?:st|nd|rd|th)?,?\s+\d{4}\b`),
+		regexp.MustCompile(`(?i)\b\d{1,2}(?:st|nd|rd|th|er|º)?\.?\s+(?:de\s+)?` + monthPattern + `\.?,?\s+(?:de\s+)?\d{4}\b`),
+		regexp.MustCompile(`(?i)\b(?:\d+|an?|one)\s+(?:second|sec|minute|min|hour|hr|day|week|month|year)s?\s+ago\b`),
+		// today and yesterday are common words, in free text they are only a date after a label like "posted"
+		regexp.MustCompile(`(?i)\b(?:posted|updated|published|released|added)(?:\s+on)?\s*:?\s*(?:yesterday|today)\b`),
+	}
+}
+
+// monthRegex builds an alternation of every month name, longest first so "june" is not matched as "jun"
+func monthRegex() string {
+	names := make([]string, 0, len(monthNames))
+	for n := range monthNames {
+		names = append(names, regexp.QuoteMeta(n))
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) > len(names[j])
+		}
+		return names[i] < names[j]
+	})
+	return "(?:" + strings.Join(names, "|") + ")"
+}
+
+// ParseDate parses a date written in one of many formats, ie 2006-01-02, 02/01/2006, January 2, 2006,
+// 2 de enero de 2006, 2 janvier 2006, 2006年1月2日 or 3 days ago, a label like "Posted on" before the date is skipped
+// relative dates are calculated from now and numeric dates are read month first unless the first number can not be a month
+func ParseDate(s string, now time.Time) (time.Time, bool) {
+	s = strings.TrimSpace(dateLabel.ReplaceAllString(strings.TrimSpace(s), ""))
+	if s == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range isoLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, validDate(t)
+		}
+	}
+	lower := strings.ToLower(s)
+	switch lower {
+	case "today":
+		return truncateDay(now), true
+	case "yesterday":
+		return truncateDay(now.AddDate(0, 0, -1)), true
+	}
+	if m := relativeDate.FindStringSubmatch(lower); m != nil {
+		return relative(m[1], m[2], now), true
+	}
+	if m := cjkDate.FindStringSubmatch(s); m != nil {
+		return buildDate(atoi(m[1]), atoi(m[2]), atoi(m[3]), "")
+	}
+	if m := numericDate.FindStringSubmatch(s); m != nil {
+		a, b, c := atoi(m[1]), atoi(m[2]), atoi(m[3])
+		switch {
+		case len(m[1]) == 4:
+			return buildDate(a, b, c, "")
+		case a > 12:
+			return buildDate(fullYear(c, len(m[3])), b, a, "")
+		default:
+			return buildDate(fullYear(c, len(m[3])), a, b, "")
+		}
+	}
+	return parseWrittenDate(lower)
+}
+
+// parseWrittenDate reads dates that use a month name in any order, ie "jan 2, 2006" or "2. märz 2006 15:04"
+func parseWrittenDate(s string) (time.Time, bool) {
+	clock := ""
+	if m := clockTime.FindString(s); m != "" {
+		clock = m
+		s = strings.Replace(s, m, " ", 1)
+	}
+	s = ordinalSuffix.ReplaceAllString(s, "$1")
+	var month time.Month
+	year, day := 0, 0
+	for _, w := range dateWordSplits.Split(s, -1) {
+		if m, found := monthNames[w]; found && month == 0 {
+			month = m
+			continue
+		}
+		n, err := strconv.Atoi(w)
+		if err != nil {
+			continue
+		}
+		switch {
+		case len(w) == 4 && year == 0:
+			year = n
+		case len(w) <= 2 && day == 0:
+			day = n
+		}
+	}
+	if month == 0 || year == 0 {
+		return time.Time{}, false
+	}
+	if day == 0 {
+		day = 1
+	}
+	return buildDate(year, int(month), day, clock)
+}
+
+func buildDate(year, month, day int, clock string) (time.Time, bool) {
+	hour, minute, second := 0, 0, 0
+	if m := clockTime.FindStringSubmatch(clock); m != nil {
+		hour, minute, second = atoi(m[1]), atoi(m[2]), atoi(m[3])
+		if strings.EqualFold(m[4], "pm") && hour < 12 {
+			hour += 12
+		} else if strings.EqualFold(m[4], "am") && hour == 12 {
+			hour = 0
+		}
+	}
+	t := time.Date(year, time.Month(month), day, hour, minute, second, 0, time.UTC)
+	// time.Date normalizes impossible dates like the 31st of February, those are not real dates
+	if t.Day() != day || int(t.Month()) != month {
+		return time.Time{}, false
+	}
+	return t, validDate(t)
+}
+
+func validDate(t time.Time) bool {
+	return t.Year() >= 1900 && t.Year() <= 2200
+}
+
+func fullYear(year, digits int) int {
+	if digits > 2 {
+		return year
+	}
+	if year < 70 {
+		return 2000 + year
+	}
+	return 1900 + year
+}
+
+func relative(amount, unit string, now time.Time) time.Time {
+	n, err := strconv.Atoi(amount)
+	if err != nil {
+		n = 1
+	}
+	switch unit {
+	case "second", "sec":
+		return now.Add(-time.Duration(n) * time.Second)
+	case "minute", "min":
+		return now.Add(-time.Duration(n) * time.Minute)
+	case "hour", "hr":
+		return now.Add(-time.Duration(n) * time.Hour)
+	case "day":
+		return truncateDay(now.AddDate(0, 0, -n))
+	case "week":
+		return truncateDay(now.AddDate(0, 0, -7*n))
+	case "month":
+		return truncateDay(now.AddDate(0, -n, 0))
+	}
+	return truncateDay(now.AddDate(-n, 0, 0))
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// dateElement is true for elements whose whole text is a date, <time> and elements with a date like class, id or itemprop
+func dateElement(n *v2.HtmlData) bool {
+	if strings.EqualFold(n.Tag, "time") {
+		return true
+	}
+	for _, attr := range []string{"class", "id", "itemprop"} {
+		if dateHint.MatchString(n.Attributes[attr]) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindDates returns every date found in free text in the order they appear
+// today and yesterday are only read after a label like "posted", elements holding a date go to ParseDate, see dateElement
+func FindDates(text string, now time.Time) []time.Time {
+	type found struct {
+		start int
+		t     time.Time
+	}
+	var matches []found
+	used := make([]bool, len(text))
+	for _, p := range datePatterns {
+		for _, loc := range p.FindAllStringIndex(text, -1) {
+			if used[loc[0]] || used[loc[1]-1] {
+				continue
+			}
+			t, ok := ParseDate(text[loc[0]:loc[1]], now)
+			if !ok {
+				continue
+			}
+			for i := loc[0]; i < loc[1]; i++ {
+				used[i] = true
+			}
+			matches = append(matches, found{start: loc[0], t: t})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].start < matches[j].start
+	})
+	output := make([]time.Time, 0, len(matches))
+	for _, m := range matches {
+		output = append(output, m.t)
+	}
+	return output
+}
@@ -0,0 +1,39 @@
+package analyzer
+
+import (
+	"testing"
+	"time"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+)
+
+func TestFindDatesRelativeWords(t *testing.T) {
+	now := time.Date(2020, time.May, 10, 12, 0, 0, 0, time.UTC)
+	if got := FindDates("Subscribe today for updates, what happened yesterday?", now); len(got) != 0 {
+		t.Errorf("free text dates = %v, want none", got)
+	}
+	got := FindDates("Chapter 12 posted yesterday by admin", now)
+	if want := time.Date(2020, time.May, 9, 0, 0, 0, 0, time.UTC); len(got) != 1 || !got[0].Equal(want) {
+		t.Errorf("labeled dates = %v, want [%v]", got, want)
+	}
+}
+
+func TestAutoDatesRelativeWords(t *testing.T) {
+	page, err := v2.NewHTMLSourceRequest().ProcessSourceCode(`<html><body><p>Subscribe today for updates</p></body></html>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := NewAuto().Dates(page); len(got) != 0 {
+		t.Errorf("dates = %v, want none", got)
+	}
+	page, err = v2.NewHTMLSourceRequest().ProcessSourceCode(`<html><body><span class="post-date">Today</span></body></html>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewAuto()
+	now := time.Date(2020, time.May, 10, 23, 59, 59, 0, time.UTC)
+	a.Now = func() time.Time { return now }
+	if got := a.Dates(page); len(got) != 1 || !got[0].Equal(truncateDay(now)) {
+		t.Errorf("dates = %v, want [%v]", got, truncateDay(now))
+	}
+}
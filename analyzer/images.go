@@ -0,0 +1,277 @@
+package analyzer
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+)
+
+// Image is an image of the page in reading order
+type Image struct {
+	URL string `json:"url"`
+	Alt string `json:"alt"`
+	// Width and Height come from the attributes or the chosen srcset candidate, 0 when unknown
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Source is how the image was found: img, picture, link, background or script
+	Source string `json:"source"`
+
+	node *v2.HtmlData
+}
+
+var (
+	// lazyAttributes hold the real url of lazy loaded images, src is often a placeholder until the image is scrolled to
+	lazyAttributes  = []string{"data-src", "data-lazy-src", "data-original", "data-lazy", "data-url", "data-echo", "data-orig-file", "data-full-url"}
+	srcsetAttrs     = []string{"data-srcset", "data-lazy-srcset", "srcset"}
+	backgroundAttrs = []string{"data-bg", "data-background", "data-background-image", "data-bg-src"}
+	srcsetCandidate = regexp.MustCompile(`\s*([^\s,][^\s]*?)(?:\s+([\d.]+)([wx]))?\s*(?:,|$)`)
+	cssURL          = regexp.MustCompile(`(?i)background(?:-image)?\s*:[^;]*?url\(\s*['"]?([^'")]+)['"]?\s*\)`)
+	sizeStyle       = regexp.MustCompile(`(?i)(?:^|;)\s*(width|height)\s*:\s*(\d+)px`)
+	imageExtension  = regexp.MustCompile(`(?i)\.(?:jpe?g|png|gif|webp|avif|bmp|jfif)(?:$|[?#])`)
+	scriptImage     = regexp.MustCompile(`(?i)(?:https?:)?(?:\\?/){2}[^\s"'<>,]+?\.(?:jpe?g|png|gif|webp|avif|bmp|jfif)(?:\?[^\s"'<>,]*)?`)
+	ignoredImage    = regexp.MustCompile(`(?i)(^|[\s_/.-])(icons?|logo|avatar|emoji|emoticon|sprite|spinner|loader|pixel|tracking|tracker|badge|spacer|blank|placeholder|gravatar|favicon|ads?|advert)([\s_/.-]|$)`)
+	placeholderSrc  = regexp.MustCompile(`(?i)^data:|(^|/)(blank|spacer|pixel|transparent|placeholder|lazy|loading)[^/]*\.(gif|png|svg)$`)
+)
+
+// minImageSize is the size under which an image with both dimensions known is treated as an icon
+const minImageSize = 48
+
+// ExtractImages returns the content images of a page in reading order, lazy loaded urls and the largest srcset candidate are preferred
+// over src, <picture> sources, links to full size images and css backgrounds are included, icons and tracking pixels are left out
+// when the page shows no content image, as reader pages building their pages from a js array do, the image urls of the
+// scripts are returned instead, json-ld is metadata and is never read for images
+func ExtractImages(data *v2.HtmlData, baseLink string) []*Image {
+	base, _ := url.Parse(baseLink)
+	var output []*Image
+	seen := map[string]struct{}{}
+	handled := map[*v2.HtmlData]struct{}{}
+	add := func(img *Image) {
+		if img == nil || img.URL == "" || ignoredImage.MatchString(img.URL) {
+			return
+		}
+		if _, found := seen[img.URL]; found {
+			return
+		}
+		seen[img.URL] = struct{}{}
+		output = append(output, img)
+	}
+	for _, n := range data.Elements() {
+		if _, found := handled[n]; found {
+			continue
+		}
+		switch strings.ToLower(n.Tag) {
+		case "head", "noscript", "template":
+			// noscript repeats lazy loaded images and the head holds no content images
+			for _, c := range n.GetTags(nil) {
+				handled[c] = struct{}{}
+			}
+		case "picture":
+			for _, c := range n.GetTags([]string{"source", "img"}) {
+				handled[c] = struct{}{}
+			}
+			add(pictureImage(n, base))
+		case "img":
+			add(imgImage(n, base))
+		case "a":
+			// a thumbnail linking to its full size image uses the full size url
+			if href := n.Attributes["href"]; imageExtension.MatchString(href) {
+				if imgs := n.GetTags([]string{"img"}); len(imgs) > 0 {
+					img := imgImage(imgs[0], base)
+					handled[imgs[0]] = struct{}{}
+					if img != nil {
+						img.URL, img.Source, img.Width, img.Height = resolveURL(base, href), "link", 0, 0
+						add(img)
+					}
+				}
+			}
+		}
+		if _, found := handled[n]; !found {
+			add(backgroundImage(n, base))
+		}
+	}
+	if len(output) == 0 {
+		return scriptImages(data, base)
+	}
+	return output
+}
+
+func imgImage(n *v2.HtmlData, base *url.URL) *Image {
+	if ignoredNode(n) {
+		return nil
+	}
+	img := &Image{Alt: strings.TrimSpace(n.Attributes["alt"]), Source: "img", node: n}
+	img.Width, img.Height = nodeSize(n)
+	if isIcon(img.Width, img.Height) {
+		return nil
+	}
+	for _, attr := range srcsetAttrs {
+		if u, w := bestSrcset(n.Attributes[attr]); u != "" {
+			img.URL = resolveURL(base, u)
+			if w > 0 {
+				img.Width, img.Height = scaleSize(img.Width, img.Height, w)
+			}
+			return img
+		}
+	}
+	for _, attr := range append(lazyAttributes, "src") {
+		v := strings.TrimSpace(n.Attributes[attr])
+		if v == "" || placeholderSrc.MatchString(v) {
+			continue
+		}
+		img.URL = resolveURL(base, v)
+		return img
+	}
+	return nil
+}
+
+// pictureImage picks the largest candidate of all <source> elements and the fallback <img>
+func pictureImage(n *v2.HtmlData, base *url.URL) *Image {
+	var fallback *Image
+	if imgs := n.GetTags([]string{"img"}); len(imgs) > 0 {
+		fallback = imgImage(imgs[0], base)
+		if fallback == nil && ignoredNode(imgs[0]) {
+			return nil
+		}
+	}
+	best, bestWidth := "", -1
+	for _, s := range n.GetTags([]string{"source"}) {
+		for _, attr := range srcsetAttrs {
+			if u, w := bestSrcset(s.Attributes[attr]); u != "" && w > bestWidth {
+				best, bestWidth = u, w
+			}
+		}
+	}
+	if best == "" {
+		if fallback != nil {
+			fallback.Source = "picture"
+		}
+		return fallback
+	}
+	img := &Image{URL: resolveURL(base, best), Source: "picture", node: n}
+	if fallback != nil {
+		img.Alt, img.Width, img.Height = fallback.Alt, fallback.Width, fallback.Height
+		if bestWidth > 0 {
+			img.Width, img.Height = scaleSize(fallback.Width, fallback.Height, bestWidth)
+		}
+	} else if bestWidth > 0 {
+		img.Width = bestWidth
+	}
+	return img
+}
+
+func backgroundImage(n *v2.HtmlData, base *url.URL) *Image {
+	v := ""
+	for _, attr := range backgroundAttrs {
+		if v = strings.TrimSpace(n.Attributes[attr]); v != "" {
+			break
+		}
+	}
+	if v == "" {
+		if m := cssURL.FindStringSubmatch(n.Attributes["style"]); m != nil {
+			v = strings.TrimSpace(m[1])
+		}
+	}
+	if v == "" || placeholderSrc.MatchString(v) || ignoredNode(n) {
+		return nil
+	}
+	img := &Image{URL: resolveURL(base, v), Source: "background", node: n}
+	img.Alt = strings.TrimSpace(firstString(n.Attributes["aria-label"], n.Attributes["title"]))
+	img.Width, img.Height = nodeSize(n)
+	if isIcon(img.Width, img.Height) {
+		return nil
+	}
+	return img
+}
+
+// scriptImages returns the image urls held in scripts, readers often keep the list of pages in a js array
+func scriptImages(data *v2.HtmlData, base *url.URL) []*Image {
+	var output []*Image
+	seen := map[string]struct{}{}
+	for _, s := range data.GetTags([]string{"script"}) {
+		if strings.EqualFold(strings.TrimSpace(s.Attributes["type"]), "application/ld+json") {
+			continue
+		}
+		for _, m := range scriptImage.FindAllString(s.TextData, -1) {
+			u := strings.ReplaceAll(m, `\/`, "/")
+			if ignoredImage.MatchString(u) {
+				continue
+			}
+			u = resolveURL(base, u)
+			if _, found := seen[u]; found {
+				continue
+			}
+			seen[u] = struct{}{}
+			output = append(output, &Image{URL: u, Source: "script", node: s})
+		}
+	}
+	return output
+}
+
+// bestSrcset returns the candidate with the highest width or density and its width, 0 when the descriptor is a density
+func bestSrcset(srcset string) (string, int) {
+	best, bestValue, bestWidth := "", -1.0, 0
+	for _, m := range srcsetCandidate.FindAllStringSubmatch(srcset, -1) {
+		if placeholderSrc.MatchString(m[1]) {
+			continue
+		}
+		value, width := 1.0, 0
+		if m[2] != "" {
+			value, _ = strconv.ParseFloat(m[2], 64)
+			if m[3] == "w" {
+				width = int(value)
+			}
+		}
+		if value > bestValue {
+			best, bestValue, bestWidth = m[1], value, width
+		}
+	}
+	return best, bestWidth
+}
+
+// nodeSize reads the width and height attributes, falling back to pixel sizes in the style
+func nodeSize(n *v2.HtmlData) (int, int) {
+	width, _ := strconv.Atoi(strings.TrimSuffix(n.Attributes["width"], "px"))
+	height, _ := strconv.Atoi(strings.TrimSuffix(n.Attributes["height"], "px"))
+	for _, m := range sizeStyle.FindAllStringSubmatch(n.Attributes["style"], -1) {
+		v, _ := strconv.Atoi(m[2])
+		if strings.EqualFold(m[1], "width") && width == 0 {
+			width = v
+		} else if strings.EqualFold(m[1], "height") && height == 0 {
+			height = v
+		}
+	}
+	return width, height
+}
+
+// scaleSize sets the width to the srcset width and scales the height to keep the ratio
+func scaleSize(width, height, target int) (int, int) {
+	if width > 0 && height > 0 {
+		return target, height * target / width
+	}
+	return target, 0
+}
+
+// isIcon is true for tracking pixels and images too small to be content
+func isIcon(width, height int) bool {
+	if width == 1 || height == 1 {
+		return true
+	}
+	return width > 0 && height > 0 && width < minImageSize && height < minImageSize
+}
+
+// ignoredNode is true for images named like icons, logos, avatars, ads or tracking pixels
+// the url itself is checked once chosen since a placeholder src does not matter when a lazy url replaces it
+func ignoredNode(n *v2.HtmlData) bool {
+	if strings.EqualFold(n.Attributes["role"], "presentation") || strings.EqualFold(n.Attributes["aria-hidden"], "true") {
+		return true
+	}
+	for _, attr := range []string{"class", "id"} {
+		if ignoredImage.MatchString(n.Attributes[attr]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+)
+
+func imageURLs(t *testing.T, body string) []string {
+	t.Helper()
+	page, err := v2.NewHTMLSourceRequest().ProcessSourceCode("<html><body>" + body + "</body></html>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var urls []string
+	for _, img := range ExtractImages(page, "https://example.com/") {
+		urls = append(urls, img.URL)
+	}
+	return urls
+}
+
+func TestExtractImagesKeepsPageImages(t *testing.T) {
+	got := imageURLs(t, `<img src="/cover.jpg">`+
+		`<script type="application/ld+json">{"image": ["https://example.com/a.jpg", "https://example.com/b.jpg"]}</script>`+
+		`<script>var related = ["https://example.com/r1.jpg", "https://example.com/r2.jpg"]</script>`)
+	if want := []string{"https://example.com/cover.jpg"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("images = %q, want %q", got, want)
+	}
+}
+
+func TestExtractImagesFromScripts(t *testing.T) {
+	got := imageURLs(t, `<div id="reader"></div>`+
+		`<script type="application/ld+json">{"image": "https://example.com/thumb.jpg"}</script>`+
+		`<script>var pages = ["https:\/\/cdn.example.com\/1.jpg", "https:\/\/cdn.example.com\/2.jpg"]</script>`)
+	if want := []string{"https://cdn.example.com/1.jpg", "https://cdn.example.com/2.jpg"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("images = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,143 @@
+package analyzer
+
+import (
+	"strings"
+	"unicode"
+)
+
+// stopWords are frequent short words used to tell latin script languages apart
+var stopWords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "in", "that", "it", "was", "for", "with", "you", "he", "she", "on", "are", "this", "be", "at", "his", "her", "they", "have", "not"},
+	"es": {"el", "la", "de", "que", "y", "en", "los", "las", "del", "se", "por", "con", "una", "para", "es", "su", "al", "lo", "como", "pero", "muy", "está"},
+	"fr": {"le", "la", "les", "de", "des", "et", "est", "un", "une", "du", "que", "qui", "dans", "pour", "pas", "sur", "au", "avec", "il", "elle", "ce", "sont", "nous", "vous"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "zu", "den", "mit", "sich", "des", "auf", "für", "im", "dem", "sie", "er", "es", "ich", "auch", "wird", "von"},
+	"pt": {"o", "a", "os", "as", "de", "que", "e", "do", "da", "em", "um", "uma", "para", "com", "não", "no", "na", "se", "por", "mais", "dos", "das", "ele", "ela"},
+	"it": {"il", "lo", "la", "di", "che", "e", "un", "una", "per", "non", "del", "della", "con", "sono", "gli", "le", "nel", "è", "si", "anche", "questo", "come"},
+	"nl": {"de", "het", "een", "en", "van", "is", "dat", "niet", "op", "te", "zijn", "voor", "met", "die", "ik", "je", "maar", "ook", "er", "aan", "wordt"},
+	"id": {"dan", "yang", "di", "ini", "itu", "dengan", "untuk", "tidak", "dari", "dalam", "akan", "pada", "ke", "juga", "ada", "saya", "kamu", "mereka", "sudah"},
+	"tr": {"ve", "bir", "bu", "da", "de", "için", "ile", "çok", "ne", "ama", "gibi", "daha", "olarak", "var", "ben", "sen", "o", "mi", "değil"},
+	"pl": {"i", "w", "nie", "na", "się", "z", "jest", "do", "to", "że", "jak", "ale", "od", "po", "co", "tak", "za", "jego", "jej"},
+	"vi": {"và", "của", "là", "có", "không", "một", "những", "được", "cho", "trong", "người", "này", "với", "đã", "các", "anh", "cô", "tôi"},
+}
+
+// DetectLanguage guesses the language of a text returning an ISO 639-1 code or an empty string when there is not enough text
+// the writing system decides most non latin languages, latin script text is scored by how many of its words are common short words
+func DetectLanguage(text string) string {
+	scripts := map[string]int{}
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			scripts["ja"]++
+		case unicode.Is(unicode.Hangul, r):
+			scripts["ko"]++
+		case unicode.Is(unicode.Han, r):
+			scripts["han"]++
+		case unicode.Is(unicode.Cyrillic, r):
+			scripts["cyrillic"]++
+			if strings.ContainsRune("іїєґ", unicode.ToLower(r)) {
+				scripts["uk"]++
+			}
+		case unicode.Is(unicode.Arabic, r):
+			scripts["ar"]++
+		case unicode.Is(unicode.Hebrew, r):
+			scripts["he"]++
+		case unicode.Is(unicode.Thai, r):
+			scripts["th"]++
+		case unicode.Is(unicode.Greek, r):
+			scripts["el"]++
+		case unicode.Is(unicode.Devanagari, r):
+			scripts["hi"]++
+		case unicode.Is(unicode.Latin, r):
+			scripts["latin"]++
+		}
+	}
+	if letters < 10 {
+		return ""
+	}
+	best, count := "", 0
+	for s, c := range scripts {
+		if s == "uk" {
+			continue
+		}
+		if c > count || (c == count && s < best) {
+			best, count = s, c
+		}
+	}
+	switch best {
+	case "han":
+		// japanese mixes kanji with kana, text with any real amount of kana is japanese
+		if scripts["ja"]*10 > scripts["han"] {
+			return "ja"
+		}
+		return "zh"
+	case "ja":
+		return "ja"
+	case "cyrillic":
+		if scripts["uk"] > 0 {
+			return "uk"
+		}
+		return "ru"
+	case "latin":
+		return detectLatin(text)
+	}
+	return best
+}
+
+func detectLatin(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	if len(words) < 3 {
+		return ""
+	}
+	best, bestScore := "", 0
+	for lang, list := range stopWords {
+		set := map[string]struct{}{}
+		for _, w := range list {
+			set[w] = struct{}{}
+		}
+		score := 0
+		for _, w := range words {
+			if _, found := set[w]; found {
+				score++
+			}
+		}
+		if score > bestScore || (score == bestScore && lang < best) {
+			best, bestScore = lang, score
+		}
+	}
+	// a couple of matches in a long text is more likely to be noise than a real signal
+	if bestScore < 2 || bestScore*20 < len(words) {
+		return ""
+	}
+	return best
+}
+
+// normalizeLanguage turns a language tag into the usual form, ie EN_us becomes en-US
+func normalizeLanguage(tag string) string {
+	tag = strings.TrimSpace(strings.ReplaceAll(tag, "_", "-"))
+	if i := strings.IndexAny(tag, ",;"); i >= 0 {
+		tag = tag[:i]
+	}
+	parts := strings.Split(tag, "-")
+	if len(parts[0]) < 2 || len(parts[0]) > 3 {
+		return ""
+	}
+	parts[0] = strings.ToLower(parts[0])
+	for i := 1; i < len(parts); i++ {
+		switch len(parts[i]) {
+		case 2:
+			parts[i] = strings.ToUpper(parts[i])
+		case 4:
+			parts[i] = strings.ToUpper(parts[i][:1]) + strings.ToLower(parts[i][1:])
+		default:
+			parts[i] = strings.ToLower(parts[i])
+		}
+	}
+	return strings.Join(parts, "-")
+}
@@ -0,0 +1,476 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"strings"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+)
+
+// Metadata is the machine readable information a page embeds about itself
+// the top level fields are resolved from JSON-LD first, then Microdata, RDFa, OpenGraph, Twitter cards and plain meta tags
+type Metadata struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Authors     []string `json:"authors"`
+	Image       string   `json:"image"`
+	URL         string   `json:"url"`
+	SiteName    string   `json:"site_name"`
+	Type        string   `json:"type"`
+	Language    string   `json:"language"`
+	Published   string   `json:"published"`
+	Modified    string   `json:"modified"`
+	Keywords    []string `json:"keywords"`
+	Genres      []string `json:"genres"`
+
+	JSONLD    []map[string]interface{} `json:"json_ld"`
+	Microdata []*Item                  `json:"microdata"`
+	RDFa      []*Item                  `json:"rdfa"`
+	OpenGraph map[string][]string      `json:"open_graph"`
+	Twitter   map[string]string        `json:"twitter"`
+	// Meta holds every other <meta name> and <meta http-equiv> tag keyed by the lower case name
+	Meta map[string]string `json:"meta"`
+
+	jsonLDNodes []*v2.HtmlData
+	metaNodes   map[string]*v2.HtmlData
+	origins     map[string]origin
+}
+
+// origin records where a resolved metadata field was read from
+type origin struct {
+	source Source
+	node   *v2.HtmlData
+}
+
+// candidate is a possible value for a resolved field, candidates are tried in order of trust
+type candidate struct {
+	values []string
+	origin
+}
+
+// Item is a schema.org item found from Microdata or RDFa
+// property values are either a string or a nested *Item
+type Item struct {
+	Type       []string                 `json:"type"`
+	ID         string                   `json:"id"`
+	Properties map[string][]interface{} `json:"properties"`
+
+	node *v2.HtmlData
+}
+
+// mainEntityTypes are schema.org types that describe the content of a page rather than the site around it
+var mainEntityTypes = []string{
+	"article", "newsarticle", "blogposting", "report", "scholarlyarticle", "techarticle", "book", "bookseries",
+	"comicseries", "comicissue", "comicstory", "creativework", "creativeworkseries", "movie", "tvseries",
+	"episode", "product", "recipe", "videoobject", "musicalbum", "musicrecording", "podcastseries",
+	"profilepage", "person", "event", "course", "webpage", "itempage", "collectionpage",
+}
+
+// ExtractMetadata collects the JSON-LD, Microdata, RDFa, OpenGraph, Twitter and meta tag data from a page
+func ExtractMetadata(data *v2.HtmlData) *Metadata {
+	md := &Metadata{
+		OpenGraph: map[string][]string{},
+		Twitter:   map[string]string{},
+		Meta:      map[string]string{},
+		metaNodes: map[string]*v2.HtmlData{},
+		origins:   map[string]origin{},
+	}
+	md.JSONLD, md.jsonLDNodes = extractJSONLD(data)
+	md.Microdata = extractItems(data, "itemscope", "itemprop")
+	md.RDFa = extractItems(data, "typeof", "property")
+	for _, m := range data.GetTags([]string{"meta"}) {
+		content := strings.TrimSpace(m.Attributes["content"])
+		if content == "" {
+			continue
+		}
+		property := strings.ToLower(strings.TrimSpace(m.Attributes["property"]))
+		name := strings.ToLower(strings.TrimSpace(m.Attributes["name"]))
+		key := ""
+		switch {
+		case strings.HasPrefix(property, "og:") || strings.HasPrefix(property, "article:") ||
+			strings.HasPrefix(property, "book:") || strings.HasPrefix(property, "profile:"):
+			key = property
+			md.OpenGraph[property] = append(md.OpenGraph[property], content)
+		case strings.HasPrefix(name, "twitter:"):
+			key = name
+			md.Twitter[name] = content
+		case strings.HasPrefix(property, "twitter:"):
+			key = property
+			md.Twitter[property] = content
+		case name != "":
+			key = name
+			md.Meta[name] = content
+		case m.Attributes["http-equiv"] != "":
+			key = strings.ToLower(m.Attributes["http-equiv"])
+			md.Meta[key] = content
+		}
+		if _, found := md.metaNodes[key]; !found && key != "" {
+			md.metaNodes[key] = m
+		}
+	}
+	md.resolve()
+	return md
+}
+
+func (md *Metadata) resolve() {
+	main, mainNode := md.mainEntity()
+	item := md.mainItem()
+	ld := func(key string) candidate {
+		return candidate{values: jsonStrings(main, key), origin: origin{source: SourceJSONLD, node: mainNode}}
+	}
+	it := func(key string) candidate {
+		c := candidate{values: item.values(key), origin: origin{source: SourceMicrodata}}
+		if item != nil {
+			c.node = item.node
+		}
+		return c
+	}
+	meta := func(key string) candidate {
+		c := candidate{origin: origin{source: SourceMeta, node: md.metaNodes[key]}}
+		switch {
+		case len(md.OpenGraph[key]) > 0:
+			c.values = md.OpenGraph[key]
+		case md.Twitter[key] != "":
+			c.values = []string{md.Twitter[key]}
+		case md.Meta[key] != "":
+			c.values = []string{md.Meta[key]}
+		}
+		return c
+	}
+	typeName := it("")
+	typeName.values = item.typeName()
+
+	md.Title = md.pick("title", ld("headline"), ld("name"), it("headline"), it("name"), meta("og:title"),
+		meta("twitter:title"), meta("title"))
+	md.Description = md.pick("description", ld("description"), it("description"), meta("og:description"),
+		meta("twitter:description"), meta("description"))
+	md.Image = md.pick("image", ld("image"), ld("thumbnailUrl"), it("image"), meta("og:image"), meta("og:image:url"),
+		meta("twitter:image"), meta("twitter:image:src"))
+	md.URL = md.pick("url", ld("url"), it("url"), meta("og:url"))
+	md.SiteName = md.pick("site_name", meta("og:site_name"), meta("application-name"), meta("twitter:site"))
+	md.Type = md.pick("type", ld("@type"), typeName, meta("og:type"))
+	md.Language = md.pick("language", ld("inLanguage"), it("inLanguage"), meta("og:locale"), meta("content-language"),
+		meta("language"))
+	md.Published = md.pick("published", ld("datePublished"), it("datePublished"), meta("article:published_time"),
+		meta("book:release_date"), meta("date"), meta("pubdate"))
+	md.Modified = md.pick("modified", ld("dateModified"), it("dateModified"), meta("article:modified_time"),
+		meta("og:updated_time"), meta("last-modified"))
+
+	md.Authors = md.pickList("authors", ld("author"), ld("creator"), it("author"), meta("article:author"),
+		meta("book:author"), split(meta("author")))
+	md.Keywords = md.pickList("keywords", split(ld("keywords")), it("keywords"), meta("article:tag"), meta("book:tag"),
+		split(meta("keywords")))
+	md.Genres = md.pickList("genres", ld("genre"), it("genre"), meta("article:section"))
+}
+
+// pick returns the first value of the first candidate that has one and records where it came from
+func (md *Metadata) pick(field string, candidates ...candidate) string {
+	for _, c := range candidates {
+		if v := firstValue(c.values); v != "" {
+			md.origins[field] = c.origin
+			return v
+		}
+	}
+	return ""
+}
+
+// pickList returns the values of the first candidate that has any, cleaned of duplicates
+func (md *Metadata) pickList(field string, candidates ...candidate) []string {
+	for _, c := range candidates {
+		if u := uniqueStrings(c.values); len(u) > 0 {
+			md.origins[field] = c.origin
+			return u
+		}
+	}
+	return nil
+}
+
+// origin returns where a resolved field was read from, the source is empty when the field has no value
+func (md *Metadata) origin(field string) origin {
+	return md.origins[field]
+}
+
+func split(c candidate) candidate {
+	c.values = splitList(strings.Join(c.values, ","))
+	return c
+}
+
+// mainEntity returns the JSON-LD object that best describes the page content and the script it came from
+func (md *Metadata) mainEntity() (map[string]interface{}, *v2.HtmlData) {
+	for _, t := range mainEntityTypes {
+		for i, obj := range md.JSONLD {
+			for _, objType := range jsonStrings(obj, "@type") {
+				if strings.EqualFold(objType, t) {
+					return obj, md.jsonLDNodes[i]
+				}
+			}
+		}
+	}
+	for i, obj := range md.JSONLD {
+		if _, found := obj["name"]; found {
+			return obj, md.jsonLDNodes[i]
+		}
+	}
+	return nil, nil
+}
+
+// mainItem returns the Microdata or RDFa item that best describes the page content
+func (md *Metadata) mainItem() *Item {
+	items := append(append([]*Item{}, md.Microdata...), md.RDFa...)
+	for _, t := range mainEntityTypes {
+		for _, item := range items {
+			for _, itemType := range item.Type {
+				if strings.EqualFold(schemaName(itemType), t) {
+					return item
+				}
+			}
+		}
+	}
+	if len(items) > 0 {
+		return items[0]
+	}
+	return nil
+}
+
+func (i *Item) typeName() []string {
+	if i == nil {
+		return nil
+	}
+	var names []string
+	for _, t := range i.Type {
+		names = append(names, schemaName(t))
+	}
+	return names
+}
+
+// value returns the first string value of a property, nested items return their name
+func (i *Item) value(property string) string {
+	return firstValue(i.values(property))
+}
+
+// values returns all string values of a property, nested items return their name or url
+func (i *Item) values(property string) []string {
+	if i == nil {
+		return nil
+	}
+	var output []string
+	for _, v := range i.Properties[property] {
+		switch t := v.(type) {
+		case string:
+			output = append(output, t)
+		case *Item:
+			output = append(output, firstString(t.value("name"), t.value("url")))
+		}
+	}
+	return output
+}
+
+// schemaName strips the vocabulary from a type, http://schema.org/Book becomes Book
+func schemaName(t string) string {
+	if i := strings.LastIndexAny(t, "/#:"); i >= 0 {
+		return t[i+1:]
+	}
+	return t
+}
+
+// extractJSONLD returns every JSON-LD object of the page together with the script node each one was read from
+func extractJSONLD(data *v2.HtmlData) ([]map[string]interface{}, []*v2.HtmlData) {
+	var output []map[string]interface{}
+	var nodes []*v2.HtmlData
+	for _, s := range data.GetTags([]string{"script"}) {
+		if !strings.EqualFold(strings.TrimSpace(s.Attributes["type"]), "application/ld+json") {
+			continue
+		}
+		text := strings.TrimSpace(s.TextData)
+		text = strings.TrimSuffix(strings.TrimPrefix(text, "<!--"), "-->")
+		var v interface{}
+		if err := json.Unmarshal([]byte(text), &v); err != nil {
+			// raw new lines inside strings are a common mistake that makes the json invalid
+			if err := json.Unmarshal([]byte(strings.NewReplacer("\n", " ", "\r", " ", "\t", " ").Replace(text)), &v); err != nil {
+				continue
+			}
+		}
+		for _, obj := range flattenJSONLD(v) {
+			output = append(output, obj)
+			nodes = append(nodes, s)
+		}
+	}
+	return output, nodes
+}
+
+// flattenJSONLD returns every object in a JSON-LD document including the ones inside @graph
+func flattenJSONLD(v interface{}) []map[string]interface{} {
+	var output []map[string]interface{}
+	switch t := v.(type) {
+	case []interface{}:
+		for _, i := range t {
+			output = append(output, flattenJSONLD(i)...)
+		}
+	case map[string]interface{}:
+		if graph, found := t["@graph"]; found {
+			output = append(output, flattenJSONLD(graph)...)
+			if _, typed := t["@type"]; !typed {
+				return output
+			}
+		}
+		output = append(output, t)
+	}
+	return output
+}
+
+// extractItems finds the Microdata (itemscope/itemprop) or RDFa (typeof/property) items of a page
+// only top level items are returned, items used as a property are reachable from their parent item
+func extractItems(data *v2.HtmlData, scopeAttribute, propertyAttribute string) []*Item {
+	var output []*Item
+	var walk func(h *v2.HtmlData)
+	walk = func(h *v2.HtmlData) {
+		if _, scope := h.Attributes[scopeAttribute]; scope {
+			output = append(output, newItem(h, scopeAttribute, propertyAttribute))
+			return
+		}
+		for _, c := range h.Child {
+			walk(c)
+		}
+		for _, c := range h.Sibling {
+			walk(c)
+		}
+	}
+	walk(data)
+	return output
+}
+
+func newItem(h *v2.HtmlData, scopeAttribute, propertyAttribute string) *Item {
+	item := &Item{Properties: map[string][]interface{}{}, node: h}
+	if scopeAttribute == "itemscope" {
+		item.Type = strings.Fields(h.Attributes["itemtype"])
+		item.ID = h.Attributes["itemid"]
+	} else {
+		vocab := h.Attributes["vocab"]
+		for _, t := range strings.Fields(h.Attributes["typeof"]) {
+			item.Type = append(item.Type, vocab+t)
+		}
+		item.ID = h.Attributes["resource"]
+	}
+	var walk func(n *v2.HtmlData)
+	walk = func(n *v2.HtmlData) {
+		for _, c := range append(append([]*v2.HtmlData{}, n.Child...), n.Sibling...) {
+			_, scope := c.Attributes[scopeAttribute]
+			if names := strings.Fields(c.Attributes[propertyAttribute]); len(names) > 0 {
+				var value interface{} = propertyValue(c)
+				if scope {
+					value = newItem(c, scopeAttribute, propertyAttribute)
+				}
+				for _, name := range names {
+					name = schemaName(name)
+					item.Properties[name] = append(item.Properties[name], value)
+				}
+			}
+			if !scope {
+				walk(c)
+			}
+		}
+	}
+	walk(h)
+	return item
+}
+
+// propertyValue returns the value of a Microdata or RDFa property following the attribute rules of each element
+func propertyValue(h *v2.HtmlData) string {
+	if v, found := h.Attributes["content"]; found {
+		return strings.TrimSpace(v)
+	}
+	switch strings.ToLower(h.Tag) {
+	case "a", "area", "link":
+		return h.Attributes["href"]
+	case "img", "audio", "embed", "iframe", "source", "track", "video":
+		return h.Attributes["src"]
+	case "object":
+		return h.Attributes["data"]
+	case "data", "meter":
+		return h.Attributes["value"]
+	case "time":
+		if v := h.Attributes["datetime"]; v != "" {
+			return v
+		}
+	}
+	return h.Text()
+}
+
+// jsonString returns the first string found for a key of a JSON-LD object
+func jsonString(obj map[string]interface{}, key string) string {
+	return firstValue(jsonStrings(obj, key))
+}
+
+// jsonStrings returns the string values of a key, objects return their name, url or @id
+func jsonStrings(obj map[string]interface{}, key string) []string {
+	if obj == nil {
+		return nil
+	}
+	return jsonValues(obj[key])
+}
+
+func jsonValues(v interface{}) []string {
+	switch t := v.(type) {
+	case string:
+		if s := strings.TrimSpace(t); s != "" {
+			return []string{s}
+		}
+	case float64, bool:
+		b, _ := json.Marshal(t)
+		return []string{string(b)}
+	case []interface{}:
+		var output []string
+		for _, i := range t {
+			output = append(output, jsonValues(i)...)
+		}
+		return output
+	case map[string]interface{}:
+		for _, key := range []string{"name", "url", "contentUrl", "@value", "@id"} {
+			if values := jsonValues(t[key]); len(values) > 0 {
+				return values[:1]
+			}
+		}
+	}
+	return nil
+}
+
+func firstString(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func firstValue(values []string) string {
+	return firstString(values...)
+}
+
+// splitList splits a comma or semicolon separated meta value
+func splitList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ';'
+	})
+}
+
+// uniqueStrings joins the lists keeping the first occurrence of every value, compared case insensitive
+func uniqueStrings(lists ...[]string) []string {
+	var output []string
+	dup := map[string]struct{}{}
+	for _, l := range lists {
+		for _, v := range l {
+			v = strings.TrimSpace(v)
+			if v == "" {
+				continue
+			}
+			key := strings.ToLower(v)
+			if _, found := dup[key]; found {
+				continue
+			}
+			dup[key] = struct{}{}
+			output = append(output, v)
+		}
+	}
+	return output
+}
@@ -0,0 +1,395 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+	"github.com/Seann-Moser/WebParser/website"
+)
+
+// PaginationKind is the mechanism a page uses to reach the next page
+type PaginationKind string
+
+const (
+	// PaginationNone is used when no next page was found
+	PaginationNone PaginationKind = ""
+	// PaginationRelNext pages declare the next page with <link rel="next"> or <a rel="next">
+	PaginationRelNext PaginationKind = "rel-next"
+	// PaginationNextLink pages have a "Next »" like link or button
+	PaginationNextLink PaginationKind = "next-link"
+	// PaginationQuery pages are numbered with a query parameter, ie ?page=2
+	PaginationQuery PaginationKind = "query"
+	// PaginationPath pages are numbered in the path, ie /page/2
+	PaginationPath PaginationKind = "path"
+	// PaginationInfiniteScroll pages load more items from an endpoint, often returning json
+	PaginationInfiniteScroll PaginationKind = "infinite-scroll"
+)
+
+// Pagination describes how to reach the next page
+type Pagination struct {
+	Kind PaginationKind `json:"kind"`
+	// Next is the absolute url of the next page, empty on the last page
+	Next string `json:"next"`
+	// Pages are the numbered page links found, in page order
+	Pages []string `json:"pages"`
+	// Current and Last are the page numbers of numbered pagination, 0 when unknown
+	Current int `json:"current"`
+	Last    int `json:"last"`
+	// Param is the query parameter holding the page number for PaginationQuery
+	Param string `json:"param"`
+}
+
+// PageFunc is called for every page FollowPages loads, returning ErrStopPages stops without an error
+type PageFunc func(pageURL string, page *v2.HtmlData) error
+
+// ErrStopPages can be returned from a PageFunc to stop following pages, it is website.ErrStopPages
+var ErrStopPages = website.ErrStopPages
+
+var (
+	nextText = regexp.MustCompile(`(?i)^\s*(?:next(?:\s+page)?|older(?:\s+(?:posts|entries))?|suivant(?:e)?|siguiente|weiter|nächste(?:\s+seite)?|` +
+		`próxima|prossima|volgende|次へ|下一页|下一頁|다음)?\s*[»›>→]*\s*$`)
+	nextHint     = regexp.MustCompile(`(?i)(^|[\s_-])next([\s_-]|$)`)
+	disabledHint = regexp.MustCompile(`(?i)(^|[\s_-])disabled([\s_-]|$)`)
+	loadMoreText = regexp.MustCompile(`(?i)^\s*(?:load|show|view|see)\s+more|more\s+results|mehr\s+laden|charger\s+plus|cargar\s+más|` +
+		`もっと見る|加载更多|더\s*보기`)
+	pageParams      = []string{"page", "p", "pg", "paged", "pagenum", "page_no", "pageno", "start_page"}
+	pagePath        = regexp.MustCompile(`(?i)/(?:page|p|pg)/(\d+)/?$`)
+	endpointAttrs   = []string{"data-next", "data-next-url", "data-next-page", "data-url", "data-endpoint", "data-ajax-url", "data-load-more", "data-href"}
+	scriptNextURL   = regexp.MustCompile(`"(?:next_page_url|nextPageUrl|next_url|nextUrl|nextPage|next_page|next)"\s*:\s*"((?:https?:)?/[^"]+)"`)
+	jsonNextKeys    = []string{"next", "next_url", "nextUrl", "next_page_url", "nextPageUrl", "next_page", "nextPage", "next_link"}
+	jsonContentKeys = []string{"html", "content", "data", "items_html", "results_html", "body", "markup"}
+)
+
+// DetectPagination finds the next page of a page, rel=next is trusted first, then "Next" links and buttons,
+// then numbered page links and finally "Load more" buttons or scripts pointing at an endpoint
+func DetectPagination(data *v2.HtmlData, pageURL string) *Pagination {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return &Pagination{}
+	}
+	p := &Pagination{}
+	numberedPages(data, base, p)
+
+	for _, l := range data.Search([]string{"link", "a"}, map[string]string{"rel": `(?i)(^|\s)next(\s|$)`}, nil) {
+		if next := linkURL(l, base); next != "" {
+			p.Kind, p.Next = PaginationRelNext, next
+			return p
+		}
+	}
+	for _, l := range data.GetTags([]string{"a", "button"}) {
+		if !isNextLink(l) {
+			continue
+		}
+		if next := linkURL(l, base); next != "" && next != base.String() {
+			p.Kind, p.Next = PaginationNextLink, next
+			return p
+		}
+	}
+	if p.Kind != PaginationNone {
+		return p
+	}
+	if endpoint := scrollEndpoint(data, base); endpoint != "" {
+		p.Kind, p.Next = PaginationInfiniteScroll, endpoint
+	}
+	return p
+}
+
+// isNextLink checks the text, aria-label, title and class of a link or button, disabled buttons are skipped
+func isNextLink(l *v2.HtmlData) bool {
+	hints := l.Attributes["class"] + " " + l.Attributes["id"]
+	if _, disabled := l.Attributes["disabled"]; disabled || disabledHint.MatchString(hints) || l.Attributes["aria-disabled"] == "true" {
+		return false
+	}
+	text := l.Text()
+	label := strings.TrimSpace(l.Attributes["aria-label"] + " " + l.Attributes["title"])
+	switch {
+	case text != "" && nextText.MatchString(text):
+		return true
+	case text == "" && label != "" && (nextText.MatchString(label) || nextHint.MatchString(label)):
+		return true
+	case text == "" && nextHint.MatchString(hints):
+		return true
+	}
+	return false
+}
+
+// numberedPages collects links whose text is a page number and finds the parameter or path holding that number
+func numberedPages(data *v2.HtmlData, base *url.URL, p *Pagination) {
+	type page struct {
+		number int
+		link   string
+	}
+	var pages []page
+	params := map[string]int{}
+	paths := 0
+	for _, l := range data.GetTags([]string{"a", "option"}) {
+		m := bareNumber.FindStringSubmatch(l.Text())
+		if m == nil || strings.Contains(m[1], ".") {
+			continue
+		}
+		n, _ := strconv.Atoi(m[1])
+		link := linkURL(l, base)
+		if link == "" {
+			continue
+		}
+		u, err := url.Parse(link)
+		if err != nil || u.Host != base.Host {
+			continue
+		}
+		found := false
+		for _, param := range pageParams {
+			if u.Query().Get(param) == m[1] {
+				params[param]++
+				found = true
+			}
+		}
+		if pm := pagePath.FindStringSubmatch(u.Path); pm != nil && pm[1] == m[1] {
+			paths++
+			found = true
+		}
+		if found || n == 1 && strings.TrimSuffix(u.Path, "/") == strings.TrimSuffix(base.Path, "/") {
+			pages = append(pages, page{number: n, link: link})
+		}
+	}
+	if len(pages) < 2 {
+		return
+	}
+	param := ""
+	for _, name := range pageParams {
+		if params[name] > params[param] {
+			param = name
+		}
+	}
+	p.Current = 1
+	if param != "" && params[param] >= paths {
+		p.Param = param
+		if c, err := strconv.Atoi(base.Query().Get(param)); err == nil {
+			p.Current = c
+		}
+	} else if paths > 0 {
+		if pm := pagePath.FindStringSubmatch(base.Path); pm != nil {
+			p.Current, _ = strconv.Atoi(pm[1])
+		}
+	} else {
+		return
+	}
+	sort.SliceStable(pages, func(i, j int) bool {
+		return pages[i].number < pages[j].number
+	})
+	seen := map[int]struct{}{}
+	for _, pg := range pages {
+		if _, found := seen[pg.number]; found {
+			continue
+		}
+		seen[pg.number] = struct{}{}
+		p.Pages = append(p.Pages, pg.link)
+		if pg.number > p.Last {
+			p.Last = pg.number
+		}
+		if pg.number == p.Current+1 {
+			p.Next = pg.link
+		}
+	}
+	if p.Next != "" {
+		p.Kind = PaginationPath
+		if p.Param != "" {
+			p.Kind = PaginationQuery
+		}
+	}
+}
+
+// scrollEndpoint returns the url a "Load more" button or an infinite scroll script fetches the next items from
+func scrollEndpoint(data *v2.HtmlData, base *url.URL) string {
+	for _, n := range data.GetTags(nil) {
+		for _, attr := range endpointAttrs {
+			v := strings.TrimSpace(n.Attributes[attr])
+			if v == "" || attr == "data-url" || attr == "data-href" {
+				// data-url and data-href are common on any widget, they only count on a load more button
+				if v == "" || !loadMoreText.MatchString(n.Text()) {
+					continue
+				}
+			}
+			if _, err := strconv.Atoi(v); err == nil {
+				// a bare page number, ie data-next-page="2", is sent to the current page
+				if next := withParam(base, "page", v); next != "" {
+					return next
+				}
+				continue
+			}
+			return resolveURL(base, v)
+		}
+	}
+	for _, s := range data.GetTags([]string{"script"}) {
+		if m := scriptNextURL.FindStringSubmatch(s.TextData); m != nil {
+			return resolveURL(base, strings.ReplaceAll(m[1], `\/`, "/"))
+		}
+	}
+	return ""
+}
+
+func linkURL(l *v2.HtmlData, base *url.URL) string {
+	for _, attr := range []string{"href", "value", "data-href", "data-url"} {
+		v := strings.TrimSpace(l.Attributes[attr])
+		if v == "" || strings.HasPrefix(v, "#") || strings.HasPrefix(strings.ToLower(v), "javascript:") {
+			continue
+		}
+		return resolveURL(base, v)
+	}
+	return ""
+}
+
+// nextParam increments the page number parameter of an url, it returns "" when there is none
+func nextParam(base *url.URL) string {
+	for _, param := range pageParams {
+		if n, err := strconv.Atoi(base.Query().Get(param)); err == nil {
+			return withParam(base, param, strconv.Itoa(n+1))
+		}
+	}
+	return ""
+}
+
+func withParam(base *url.URL, param, value string) string {
+	u := *base
+	q := u.Query()
+	q.Set(param, value)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// Pagination returns how the page links to its next page
+func (a *Auto) Pagination(data *v2.HtmlData, baseLink string) *Pagination {
+	return DetectPagination(data, baseLink)
+}
+
+// FollowPages loads startURL and every next page found by DetectPagination, calling fn for each of them
+// it stops after maxPages pages (0 for no limit), when a url was already visited, when a page repeats the previous one,
+// when ctx is done or when fn returns an error, ErrStopPages stops without returning an error
+// ctx is passed to the requests so cancelling it also stops the page being loaded and the sleep after it
+// infinite scroll endpoints returning json are read for their html fragment and their next url
+func FollowPages(ctx context.Context, req *v2.HTMLSourceRequest, startURL string, maxPages int, fn PageFunc) error {
+	endpoint := false
+	next := ""
+	loop := &website.PageLoop{
+		MaxPages: maxPages,
+		Load: func(ctx context.Context, pageURL string) (*v2.HtmlData, error) {
+			if endpoint {
+				page, n, err := loadEndpoint(ctx, req, pageURL)
+				next = n
+				return page, err
+			}
+			return req.GetSourceCodeContext(ctx, pageURL, http.MethodGet, nil)
+		},
+		Next: func(page *v2.HtmlData, pageURL string) string {
+			if next != "" {
+				n := next
+				next = ""
+				return n
+			}
+			p := DetectPagination(page, pageURL)
+			endpoint = endpoint || p.Kind == PaginationInfiniteScroll
+			return p.Next
+		},
+	}
+	return loop.Run(ctx, startURL, fn)
+}
+
+// ParserPages returns a PageFunc that runs a website.Parser over every page FollowPages loads, the page is parsed as it was
+// loaded so nothing is downloaded twice and infinite scroll endpoints give the html fragments of their response
+func ParserPages(parser *website.Parser, fn func(pageURL string, data []*v2.HtmlData, records []map[string]string) error) PageFunc {
+	return func(pageURL string, page *v2.HtmlData) error {
+		data, records, err := parser.ParseDocument(page, pageURL)
+		if err != nil {
+			return err
+		}
+		return fn(pageURL, data, records)
+	}
+}
+
+// loadEndpoint fetches an infinite scroll endpoint, json responses give their html fragments as the page and their next url
+// a page with nothing in it ends the scrolling
+func loadEndpoint(ctx context.Context, req *v2.HTMLSourceRequest, endpoint string) (*v2.HtmlData, string, error) {
+	raw, err := req.GetRawSourceContext(ctx, endpoint, http.MethodGet, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	body := strings.TrimSpace(string(raw))
+	next := ""
+	if strings.HasPrefix(body, "{") || strings.HasPrefix(body, "[") {
+		var decoded interface{}
+		if err := json.Unmarshal(raw, &decoded); err != nil {
+			return nil, "", err
+		}
+		var fragments []string
+		jsonFragments(decoded, &fragments)
+		body = strings.Join(fragments, "\n")
+		base, _ := url.Parse(endpoint)
+		if n := jsonNext(decoded); n != "" && base != nil {
+			next = resolveURL(base, n)
+		} else if base != nil {
+			next = nextParam(base)
+		}
+	}
+	if strings.TrimSpace(body) == "" {
+		return nil, "", nil
+	}
+	processor := v2.NewHTMLSourceRequest()
+	processor.IDMode = req.IDMode
+	page, err := processor.ProcessSourceCode(body)
+	return page, next, err
+}
+
+// jsonFragments collects the strings of known content keys that hold markup
+func jsonFragments(v interface{}, fragments *[]string) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for _, key := range jsonContentKeys {
+			if s, ok := t[key].(string); ok && strings.Contains(s, "<") {
+				*fragments = append(*fragments, s)
+			}
+		}
+		for _, key := range sortedKeys(t) {
+			if _, isString := t[key].(string); !isString {
+				jsonFragments(t[key], fragments)
+			}
+		}
+	case []interface{}:
+		for _, item := range t {
+			jsonFragments(item, fragments)
+		}
+	}
+}
+
+// jsonNext finds the next url in a json response, including nested "links" or "pagination" objects
+func jsonNext(v interface{}) string {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	for _, key := range jsonNextKeys {
+		if s, ok := obj[key].(string); ok && s != "" {
+			return s
+		}
+	}
+	for _, key := range []string{"links", "pagination", "paging", "meta"} {
+		if s := jsonNext(obj[key]); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,35 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+)
+
+func TestFollowPagesCancelsRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+		fmt.Fprint(w, `<html><body><p>slow</p></body></html>`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := FollowPages(ctx, v2.NewHTMLSourceRequest(), srv.URL, 0, func(string, *v2.HtmlData) error { return nil })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("FollowPages returned after %v, the request was not cancelled", elapsed)
+	}
+}
@@ -0,0 +1,295 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+	"github.com/Seann-Moser/WebParser/website"
+)
+
+// Plugin is a site specific extractor, besides Match it implements any of TitlePlugin, DescriptionPlugin, AuthorPlugin,
+// TagsPlugin, ChaptersPlugin, ImagesPlugin and PagesPlugin, Auto uses its generic heuristics for everything else
+type Plugin interface {
+	// Match reports whether the plugin handles the page
+	Match(pageURL string) bool
+}
+
+// TitlePlugin overrides Auto.Title, returning false falls back to the generic heuristics
+type TitlePlugin interface {
+	Title(data *v2.HtmlData, baseLink string) (string, bool)
+}
+
+// DescriptionPlugin overrides Auto.Description, returning false falls back to the generic heuristics
+type DescriptionPlugin interface {
+	Description(data *v2.HtmlData, baseLink string) (string, bool)
+}
+
+// AuthorPlugin overrides Auto.Author, returning false falls back to the generic heuristics
+type AuthorPlugin interface {
+	Author(data *v2.HtmlData, baseLink string) (string, bool)
+}
+
+// TagsPlugin overrides Auto.TagAuto, returning false falls back to the generic heuristics
+type TagsPlugin interface {
+	Tags(data *v2.HtmlData, baseLink string) ([]string, bool)
+}
+
+// ChaptersPlugin overrides Auto.Chapters, returning false falls back to the generic heuristics
+type ChaptersPlugin interface {
+	Chapters(data *v2.HtmlData, baseLink string) (*ChapterList, bool)
+}
+
+// ImagesPlugin overrides Auto.Images, returning false falls back to the generic heuristics
+type ImagesPlugin interface {
+	Images(data *v2.HtmlData, baseLink string) ([]*Image, bool)
+}
+
+// PagesPlugin overrides Auto.Pages, returning false falls back to the generic heuristics
+type PagesPlugin interface {
+	Pages(data *v2.HtmlData, baseLink string) ([]string, bool)
+}
+
+// HostPatterns implements Plugin.Match for a list of host patterns, embed it in a plugin to match by host
+// "example.com" matches the host and its www. form, "*.example.com" any sub domain and "*" every host
+type HostPatterns []string
+
+// Match reports whether the host of pageURL matches one of the patterns
+func (h HostPatterns) Match(pageURL string) bool {
+	u, err := url.Parse(pageURL)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, pattern := range h {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == host || "www."+pattern == host {
+			return true
+		}
+		if ok, _ := path.Match(pattern, host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// PluginRegistry holds the plugins Auto picks from, the first registered plugin matching a page is used
+type PluginRegistry struct {
+	mu      sync.RWMutex
+	plugins []Plugin
+}
+
+// DefaultPlugins is the registry used by NewAuto
+var DefaultPlugins = NewPluginRegistry()
+
+// NewPluginRegistry creates an empty registry
+func NewPluginRegistry() *PluginRegistry {
+	return &PluginRegistry{}
+}
+
+// RegisterPlugin adds plugins to DefaultPlugins
+func RegisterPlugin(plugins ...Plugin) {
+	DefaultPlugins.Register(plugins...)
+}
+
+// Register adds plugins to the registry
+func (r *PluginRegistry) Register(plugins ...Plugin) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.plugins = append(r.plugins, plugins...)
+}
+
+// Lookup returns the plugin handling pageURL or nil
+func (r *PluginRegistry) Lookup(pageURL string) Plugin {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, p := range r.plugins {
+		if p.Match(pageURL) {
+			return p
+		}
+	}
+	return nil
+}
+
+// DeclarativePlugin is a plugin written as data, every field is found with a list of website.Search
+// so sites can be added from a json file without writing Go
+type DeclarativePlugin struct {
+	Name         string `json:"name"`
+	HostPatterns `json:"hosts"`
+	// Fields maps title, description, author, tags, chapters, images or pages to the searches finding it
+	Fields map[string][]*website.Search `json:"fields"`
+}
+
+// declarativeFields are the field names a DeclarativePlugin can define
+var declarativeFields = []string{"title", "description", "author", "tags", "chapters", "images", "pages"}
+
+// LoadPlugins reads a json list of declarative plugins
+func LoadPlugins(r io.Reader) ([]*DeclarativePlugin, error) {
+	var plugins []*DeclarativePlugin
+	if err := json.NewDecoder(r).Decode(&plugins); err != nil {
+		return nil, err
+	}
+	for i, p := range plugins {
+		if p == nil {
+			return nil, fmt.Errorf("plugin %d is null", i)
+		}
+		if len(p.HostPatterns) == 0 {
+			return nil, fmt.Errorf("plugin %d (%s) has no hosts", i, p.Name)
+		}
+		for field, searches := range p.Fields {
+			if !isInList(field, declarativeFields) {
+				return nil, fmt.Errorf("plugin %s has an unknown field %q, expected one of %s", p.Name, field, strings.Join(declarativeFields, ", "))
+			}
+			if err := (&website.Parser{Name: p.Name, SearchList: searches}).Validate(); err != nil {
+				return nil, fmt.Errorf("plugin %s field %s: %v", p.Name, field, err)
+			}
+		}
+	}
+	return plugins, nil
+}
+
+func isInList(v string, list []string) bool {
+	for _, l := range list {
+		if l == v {
+			return true
+		}
+	}
+	return false
+}
+
+// find runs the searches of a field, the second value is false when the field is not defined or nothing was found
+func (p *DeclarativePlugin) find(field string, data *v2.HtmlData, baseLink string) ([]*v2.HtmlData, bool) {
+	searches, found := p.Fields[field]
+	if !found || len(searches) == 0 {
+		return nil, false
+	}
+	parser := &website.Parser{Name: p.Name, SearchList: searches}
+	nodes, _, err := parser.ParseDocument(data, baseLink)
+	if err != nil || len(nodes) == 0 {
+		return nil, false
+	}
+	return nodes, true
+}
+
+func (p *DeclarativePlugin) text(field string, data *v2.HtmlData, baseLink string) (string, bool) {
+	nodes, ok := p.find(field, data, baseLink)
+	if !ok {
+		return "", false
+	}
+	text := joinText(nodes, nil)
+	return text, text != ""
+}
+
+func (p *DeclarativePlugin) Title(data *v2.HtmlData, baseLink string) (string, bool) {
+	return p.text("title", data, baseLink)
+}
+
+func (p *DeclarativePlugin) Description(data *v2.HtmlData, baseLink string) (string, bool) {
+	return p.text("description", data, baseLink)
+}
+
+func (p *DeclarativePlugin) Author(data *v2.HtmlData, baseLink string) (string, bool) {
+	nodes, ok := p.find("author", data, baseLink)
+	if !ok {
+		return "", false
+	}
+	var authors []string
+	for _, n := range nodes {
+		authors = append(authors, splitAuthors(n.Text())...)
+	}
+	authors = uniqueStrings(authors)
+	return strings.Join(authors, ", "), len(authors) > 0
+}
+
+func (p *DeclarativePlugin) Tags(data *v2.HtmlData, baseLink string) ([]string, bool) {
+	nodes, ok := p.find("tags", data, baseLink)
+	if !ok {
+		return nil, false
+	}
+	var tags []string
+	for _, n := range nodes {
+		tags = append(tags, n.Text())
+	}
+	tags = uniqueStrings(tags)
+	return tags, len(tags) > 0
+}
+
+// Chapters reads the chapter number of every link found, links without a number are numbered by their position
+func (p *DeclarativePlugin) Chapters(data *v2.HtmlData, baseLink string) (*ChapterList, bool) {
+	nodes, ok := p.find("chapters", data, baseLink)
+	if !ok {
+		return nil, false
+	}
+	base, _ := url.Parse(baseLink)
+	list := &ChapterList{Container: nodes[0].Parent}
+	seen := map[string]struct{}{}
+	var ordered []*Chapter
+	for i, n := range nodes {
+		href := n.Attributes["href"]
+		if href == "" {
+			continue
+		}
+		c, ok := parseChapter(n.Text(), href)
+		if !ok {
+			c = &Chapter{Number: float64(i + 1), Title: n.Text()}
+		}
+		c.URL = website.NormalizeURL(resolveURL(base, href))
+		if _, found := seen[c.URL]; found {
+			continue
+		}
+		seen[c.URL] = struct{}{}
+		c.Date = chapterDate(n, n.Parent)
+		ordered = append(ordered, c)
+	}
+	if len(ordered) == 0 {
+		return nil, false
+	}
+	list.Chapters, list.Descending = sortChapters(ordered)
+	return list, true
+}
+
+// Images accepts img, picture and elements with a background, links are used as full size images
+func (p *DeclarativePlugin) Images(data *v2.HtmlData, baseLink string) ([]*Image, bool) {
+	nodes, ok := p.find("images", data, baseLink)
+	if !ok {
+		return nil, false
+	}
+	var images []*Image
+	seen := map[string]struct{}{}
+	for _, n := range nodes {
+		found := ExtractImages(n, baseLink)
+		if len(found) == 0 {
+			if l, err := n.FindLinks(baseLink, []string{"href", "src", "data-src"}); err == nil && l != "" {
+				found = []*Image{{URL: l, Source: "link", node: n}}
+			}
+		}
+		for _, img := range found {
+			if _, dup := seen[img.URL]; !dup {
+				seen[img.URL] = struct{}{}
+				images = append(images, img)
+			}
+		}
+	}
+	return images, len(images) > 0
+}
+
+func (p *DeclarativePlugin) Pages(data *v2.HtmlData, baseLink string) ([]string, bool) {
+	nodes, ok := p.find("pages", data, baseLink)
+	if !ok {
+		return nil, false
+	}
+	base, _ := url.Parse(baseLink)
+	var pages []string
+	for _, n := range nodes {
+		if l := linkURL(n, base); l != "" {
+			pages = append(pages, l)
+		}
+	}
+	pages = uniqueStrings(pages)
+	return pages, len(pages) > 0
+}
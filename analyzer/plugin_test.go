@@ -0,0 +1,27 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadPlugins(t *testing.T) {
+	for _, c := range []struct {
+		name, json, err string
+	}{
+		{"null entry", `[null]`, "plugin 0 is null"},
+		{"no hosts", `[{"name": "x"}]`, "has no hosts"},
+		{"unknown field", `[{"name": "x", "hosts": ["example.com"], "fields": {"plot": []}}]`, "unknown field"},
+		{"valid", `[{"name": "x", "hosts": ["example.com"], "fields": {"title": [{"search_type": "tag", "tag": "h1"}]}}]`, ""},
+	} {
+		plugins, err := LoadPlugins(strings.NewReader(c.json))
+		switch {
+		case c.err == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		case c.err == "" && len(plugins) != 1:
+			t.Errorf("%s: got %d plugins, want 1", c.name, len(plugins))
+		case c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)):
+			t.Errorf("%s: err = %v, want %q", c.name, err, c.err)
+		}
+	}
+}
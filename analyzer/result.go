@@ -0,0 +1,166 @@
+package analyzer
+
+import (
+	"math"
+	"time"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+)
+
+// Source is the strategy a field value was found with
+type Source string
+
+const (
+	// SourceJSONLD values come from <script type="application/ld+json"> blocks
+	SourceJSONLD Source = "json-ld"
+	// SourceMicrodata values come from schema.org Microdata or RDFa attributes
+	SourceMicrodata Source = "microdata"
+	// SourceMeta values come from OpenGraph, Twitter and other <meta> tags or declared attributes like <html lang>
+	SourceMeta Source = "meta"
+	// SourceHeuristic values come from the structure of the page like <title>, labelled fields or content scoring
+	SourceHeuristic Source = "heuristic"
+	// SourceFuzzyText values come from fuzzy matching the visible text of the page
+	SourceFuzzyText Source = "fuzzy-text"
+	// SourcePlugin values come from a site specific plugin
+	SourcePlugin Source = "plugin"
+)
+
+// pluginField is the Field of values given by a plugin, they are written for the site so they are trusted like JSON-LD
+var pluginField = Field{Confidence: 0.9, Source: SourcePlugin}
+
+// Field describes how a value of an AutoResult was found
+type Field struct {
+	// Confidence is between 0 and 1, 0 when nothing was found
+	Confidence float64 `json:"confidence"`
+	Source     Source  `json:"source"`
+	// Path is the structural path of the node the value was read from, see v2.HtmlData.Path
+	Path string `json:"path"`
+}
+
+// TextField is a single text value with how it was found
+type TextField struct {
+	Value string `json:"value"`
+	Field
+}
+
+// ListField is a list of values with how they were found
+type ListField struct {
+	Value []string `json:"value"`
+	Field
+}
+
+// DateField is a list of dates with how they were found
+type DateField struct {
+	Value []time.Time `json:"value"`
+	Field
+}
+
+// ChapterField is the chapter list of a page with how it was found
+type ChapterField struct {
+	Value      []*Chapter `json:"value"`
+	Descending bool       `json:"descending"`
+	Field
+}
+
+// ImageField is the images of a page with how they were found
+type ImageField struct {
+	Value []*Image `json:"value"`
+	Field
+}
+
+// AutoResult holds every field Auto can find on a page
+type AutoResult struct {
+	URL         string        `json:"url"`
+	Title       *TextField    `json:"title"`
+	Description *TextField    `json:"description"`
+	Author      *TextField    `json:"author"`
+	Tags        *ListField    `json:"tags"`
+	Dates       *DateField    `json:"dates"`
+	Language    *ListField    `json:"language"`
+	Chapters    *ChapterField `json:"chapters"`
+	Images      *ImageField   `json:"images"`
+	Pages       *ListField    `json:"pages"`
+	Metadata    *Metadata     `json:"metadata"`
+}
+
+// Analyze runs every extractor once and returns each value with a confidence, the strategy it was found with and the path
+// of the node it came from, baseLink is used to build absolute links for chapters, images and pages
+func (a *Auto) Analyze(page *v2.HtmlData, baseLink string) *AutoResult {
+	a = a.ForURL(baseLink)
+	md := ExtractMetadata(page)
+	return &AutoResult{
+		URL:         baseLink,
+		Title:       a.title(page, md),
+		Description: a.description(page, md),
+		Author:      a.author(page, md),
+		Tags:        a.tagField(page, md),
+		Dates:       a.dates(page, md),
+		Language:    a.language(page, md),
+		Chapters:    a.chapterField(page, baseLink),
+		Images:      a.imageField(page, baseLink),
+		Pages:       a.pageField(page, baseLink),
+		Metadata:    md,
+	}
+}
+
+// LowConfidence returns the names of the fields with a confidence below threshold, these should be checked by a person
+func (r *AutoResult) LowConfidence(threshold float64) []string {
+	var output []string
+	fields := []struct {
+		name  string
+		field Field
+	}{
+		{"title", r.Title.Field}, {"description", r.Description.Field}, {"author", r.Author.Field},
+		{"tags", r.Tags.Field}, {"dates", r.Dates.Field}, {"language", r.Language.Field},
+		{"chapters", r.Chapters.Field}, {"images", r.Images.Field}, {"pages", r.Pages.Field},
+	}
+	for _, f := range fields {
+		if f.field.Confidence < threshold {
+			output = append(output, f.name)
+		}
+	}
+	return output
+}
+
+// metadataField returns the Field of a resolved metadata value, Microdata is trusted a little less than JSON-LD
+// and meta tags a little less again since they are often filled with generic site wide values
+func metadataField(md *Metadata, name string) Field {
+	o := md.origin(name)
+	f := Field{Source: o.source, Path: nodePath(o.node)}
+	switch o.source {
+	case SourceJSONLD:
+		f.Confidence = 0.95
+	case SourceMicrodata:
+		f.Confidence = 0.9
+	case SourceMeta:
+		f.Confidence = 0.85
+	}
+	return f
+}
+
+func heuristic(confidence float64, node *v2.HtmlData) Field {
+	return Field{Confidence: confidence, Source: SourceHeuristic, Path: nodePath(node)}
+}
+
+func fuzzy(confidence float64, node *v2.HtmlData) Field {
+	return Field{Confidence: confidence, Source: SourceFuzzyText, Path: nodePath(node)}
+}
+
+func nodePath(node *v2.HtmlData) string {
+	if node == nil {
+		return ""
+	}
+	return node.Path
+}
+
+// listConfidence raises the base confidence a little for every value found, up to max
+func listConfidence(base, max float64, count int) float64 {
+	if count == 0 {
+		return 0
+	}
+	c := math.Round((base+0.02*float64(count-1))*100) / 100
+	if c > max {
+		return max
+	}
+	return c
+}
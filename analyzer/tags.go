@@ -0,0 +1,213 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+)
+
+var (
+	// tagSeparators split lists written in a single string, ie "Action, Drama | Romance"
+	tagSeparators = regexp.MustCompile(`\s*(?:[,;|•·]|\s/\s)\s*`)
+	tagTrim       = regexp.MustCompile(`^[\s#"'([{]+|[\s"'.:)\]}]+$`)
+	tagCount      = regexp.MustCompile(`\s*\(\d+\)$`)
+	tagSpace      = regexp.MustCompile(`[\s_]+`)
+)
+
+// maxTagWords is the number of words after which a string is a sentence rather than a tag
+const maxTagWords = 4
+
+// DefaultTagStopWords are dropped from every tag list, they are labels and navigation links found next to tags
+var DefaultTagStopWords = []string{
+	"tag", "genre", "keyword", "category", "tags", "genres", "keywords", "categories",
+	"home", "menu", "more", "show more", "read more", "see more", "see all", "view all", "all",
+	"next", "previous", "prev", "back", "top", "latest", "popular", "browse", "search",
+	"login", "log in", "sign in", "sign up", "register", "logout", "comment", "share", "bookmark", "subscribe",
+	"none", "n/a", "unknown", "null", "undefined",
+}
+
+// singularExceptions end in s without being plurals
+var singularExceptions = map[string]struct{}{
+	"series": {}, "news": {}, "species": {}, "physics": {}, "mathematics": {}, "politics": {}, "economics": {},
+	"gymnastics": {}, "athletics": {}, "always": {},
+}
+
+// singularIE are plurals ending in ies whose singular ends in ie, other ies plurals end in y like stories
+var singularIE = map[string]struct{}{
+	"movies": {}, "zombies": {}, "cookies": {}, "pies": {}, "ties": {}, "lies": {}, "rookies": {}, "hippies": {},
+	"goalies": {}, "selfies": {}, "smoothies": {}, "brownies": {}, "freebies": {}, "newbies": {}, "indies": {},
+	"techies": {}, "genies": {}, "collies": {}, "calories": {}, "sweeties": {}, "veggies": {}, "aussies": {},
+}
+
+// singularOE are plurals ending in oes whose singular ends in oe, other oes plurals end in o like heroes
+var singularOE = map[string]struct{}{
+	"shoes": {}, "horseshoes": {}, "toes": {}, "tiptoes": {}, "canoes": {}, "foes": {}, "oboes": {}, "hoes": {},
+	"woes": {}, "floes": {}, "throes": {}, "sloes": {},
+}
+
+// NormalizeTag returns the comparable form of a tag, lower case with single spaces, without surrounding punctuation,
+// counts like "Action (12)" and with the last word made singular so "Comics" and "comic" are the same tag
+// it is a key to compare tags with, the tags returned by NormalizeTags keep their cleaned display form
+func NormalizeTag(tag string) string {
+	tag = strings.ToLower(cleanTag(tag))
+	words := strings.Split(tag, " ")
+	words[len(words)-1] = singular(words[len(words)-1])
+	return strings.Join(words, " ")
+}
+
+// cleanTag returns the display form of a tag, trimmed of surrounding punctuation and counts with single spaces
+func cleanTag(tag string) string {
+	tag = strings.TrimSpace(tag)
+	tag = tagCount.ReplaceAllString(tag, "")
+	tag = tagTrim.ReplaceAllString(tag, "")
+	return tagSpace.ReplaceAllString(tag, " ")
+}
+
+func singular(word string) string {
+	if _, found := singularExceptions[word]; found || len(word) <= 3 {
+		return word
+	}
+	_, ie := singularIE[word]
+	_, oe := singularOE[word]
+	switch {
+	case ie, oe:
+		return word[:len(word)-1]
+	case strings.HasSuffix(word, "ies"):
+		return word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "oes"):
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "sses"), strings.HasSuffix(word, "ches"), strings.HasSuffix(word, "shes"),
+		strings.HasSuffix(word, "xes"):
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "ss"), strings.HasSuffix(word, "us"), strings.HasSuffix(word, "is"):
+		return word
+	case strings.HasSuffix(word, "s"):
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+// Taxonomy maps tags onto canonical names, every canonical name has a list of synonyms
+// tags and synonyms are compared in their NormalizeTag form so "Comics" and "comic  " share the same entry
+type Taxonomy struct {
+	// Strict drops the tags that have no canonical name
+	Strict bool
+
+	mu     sync.RWMutex
+	lookup map[string]string
+}
+
+// NewTaxonomy creates a taxonomy from canonical names and their synonyms
+func NewTaxonomy(canonical map[string][]string) *Taxonomy {
+	t := &Taxonomy{lookup: map[string]string{}}
+	for name, synonyms := range canonical {
+		t.Add(name, synonyms...)
+	}
+	return t
+}
+
+// LoadTaxonomy reads a json object of canonical names and their synonyms, ie {"Science Fiction": ["sci-fi", "scifi"]}
+func LoadTaxonomy(r io.Reader) (*Taxonomy, error) {
+	canonical := map[string][]string{}
+	if err := json.NewDecoder(r).Decode(&canonical); err != nil {
+		return nil, err
+	}
+	return NewTaxonomy(canonical), nil
+}
+
+// Add adds a canonical name and its synonyms, the name is a synonym of itself
+func (t *Taxonomy) Add(name string, synonyms ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.lookup == nil {
+		t.lookup = map[string]string{}
+	}
+	for _, s := range append([]string{name}, synonyms...) {
+		if key := NormalizeTag(s); key != "" {
+			t.lookup[key] = name
+		}
+	}
+}
+
+// Map returns the canonical name of a tag
+func (t *Taxonomy) Map(tag string) (string, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	name, found := t.lookup[NormalizeTag(tag)]
+	return name, found
+}
+
+// NormalizeTags splits and cleans a list of raw tags, tags are compared by their NormalizeTag form and the first
+// spelling of every tag is kept, stop words, numbers and sentences are dropped and tags are mapped onto the canonical
+// names of Auto.Taxonomy when it is set
+func (a *Auto) NormalizeTags(values []string) []string {
+	stop := map[string]struct{}{}
+	for _, s := range append(append([]string{}, DefaultTagStopWords...), a.TagStopWords...) {
+		stop[NormalizeTag(s)] = struct{}{}
+	}
+	var output []string
+	dup := map[string]struct{}{}
+	for _, v := range values {
+		for _, raw := range tagSeparators.Split(v, -1) {
+			tag := cleanTag(raw)
+			if !isTag(tag) {
+				continue
+			}
+			key := NormalizeTag(tag)
+			if _, found := stop[key]; found {
+				continue
+			}
+			if a.Taxonomy != nil {
+				name, found := a.Taxonomy.Map(tag)
+				if !found && a.Taxonomy.Strict {
+					continue
+				}
+				if found {
+					tag, key = name, NormalizeTag(name)
+				}
+			}
+			if _, found := dup[key]; found {
+				continue
+			}
+			dup[key] = struct{}{}
+			output = append(output, tag)
+		}
+	}
+	return output
+}
+
+// isTag is false for empty strings, numbers like chapter counts and sentences, tags like "3D" or "1960s" are kept
+func isTag(tag string) bool {
+	if tag == "" || len(strings.Fields(tag)) > maxTagWords {
+		return false
+	}
+	digits, letters := true, false
+	for _, c := range tag {
+		if !unicode.IsDigit(c) && !unicode.IsSpace(c) {
+			digits = false
+		}
+		if unicode.IsLetter(c) || unicode.IsNumber(c) {
+			letters = true
+		}
+	}
+	return letters && !digits
+}
+
+// inNavigation is true for elements of the menus, header and footer of a page, their links are not tags of the content
+func inNavigation(n *v2.HtmlData) bool {
+	for p := n; p != nil; p = p.Parent {
+		switch strings.ToLower(p.Tag) {
+		case "nav", "header", "footer", "menu":
+			return true
+		}
+		if strings.EqualFold(p.Attributes["role"], "navigation") || strings.EqualFold(p.Attributes["role"], "menu") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	for tag, want := range map[string]string{
+		"Zombies":      "zombie",
+		"Movies":       "movie",
+		"Heroes":       "hero",
+		"Potatoes":     "potato",
+		"Shoes":        "shoe",
+		"Stories":      "story",
+		"Comedies":     "comedy",
+		"Comics (12)":  "comic",
+		"Witches":      "witch",
+		"Series":       "series",
+		"Ecchi":        "ecchi",
+		" Boys  Love ": "boys love",
+	} {
+		if got := NormalizeTag(tag); got != want {
+			t.Errorf("NormalizeTag(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	got := NewAuto().NormalizeTags([]string{"3D, 4-Koma | 1960s; 12, Texas, Martial Arts", "martial art, Comics (4), comic, Tags, 18+"})
+	want := []string{"3D", "4-Koma", "1960s", "Texas", "Martial Arts", "Comics", "18+"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NormalizeTags = %q, want %q", got, want)
+	}
+}
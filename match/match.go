@@ -0,0 +1,100 @@
+// Package match scores how similar two texts are, every algorithm returns a score between 0 and 1
+// and compares the Normalize form of the texts so case, accents, punctuation and full width characters do not matter
+package match
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Algorithm names a similarity algorithm
+type Algorithm string
+
+const (
+	AlgorithmWords       Algorithm = "words"
+	AlgorithmExact       Algorithm = "exact"
+	AlgorithmLevenshtein Algorithm = "levenshtein"
+	AlgorithmDamerau     Algorithm = "damerau"
+	AlgorithmJaroWinkler Algorithm = "jaro-winkler"
+	AlgorithmTokenSort   Algorithm = "token-sort"
+	AlgorithmTokenSet    Algorithm = "token-set"
+	AlgorithmTrigram     Algorithm = "trigram"
+)
+
+var algorithms = map[Algorithm]func(a, b string) float64{
+	AlgorithmWords:       Words,
+	AlgorithmExact:       Exact,
+	AlgorithmLevenshtein: LevenshteinRatio,
+	AlgorithmDamerau:     DamerauRatio,
+	AlgorithmJaroWinkler: JaroWinkler,
+	AlgorithmTokenSort:   TokenSortRatio,
+	AlgorithmTokenSet:    TokenSetRatio,
+	AlgorithmTrigram:     Trigram,
+}
+
+// Default is the matcher used by HtmlData.Search, a word containment score with the threshold Search always had
+var Default = Matcher{Algorithm: AlgorithmWords, Threshold: 0.5}
+
+// Algorithms returns the names of the supported algorithms
+func Algorithms() []Algorithm {
+	return []Algorithm{AlgorithmWords, AlgorithmExact, AlgorithmLevenshtein, AlgorithmDamerau, AlgorithmJaroWinkler,
+		AlgorithmTokenSort, AlgorithmTokenSet, AlgorithmTrigram}
+}
+
+// ParseAlgorithm returns the algorithm with the given name, the name is case insensitive and an empty name is AlgorithmWords
+func ParseAlgorithm(name string) (Algorithm, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return AlgorithmWords, nil
+	}
+	if _, found := algorithms[Algorithm(name)]; !found {
+		return "", fmt.Errorf("unknown match algorithm %q, expected one of %v", name, Algorithms())
+	}
+	return Algorithm(name), nil
+}
+
+// Exact is 1 when the normalized texts are the same and 0 otherwise
+func Exact(a, b string) float64 {
+	na, nb := Normalize(a), Normalize(b)
+	if na == "" || na != nb {
+		return 0
+	}
+	return 1
+}
+
+// Score returns the similarity of a and b with the algorithm, unknown algorithms use AlgorithmWords
+func Score(algorithm Algorithm, a, b string) float64 {
+	if a == "" || b == "" {
+		return 0
+	}
+	if strings.EqualFold(a, b) {
+		return 1
+	}
+	score, found := algorithms[algorithm]
+	if !found {
+		score = Words
+	}
+	return score(a, b)
+}
+
+// Matcher decides whether two texts match
+type Matcher struct {
+	Algorithm Algorithm `json:"algorithm"`
+	// Threshold is the score a match has to reach
+	Threshold float64 `json:"threshold"`
+}
+
+// Score returns the similarity of a and b with the algorithm of the matcher
+func (m Matcher) Score(a, b string) float64 {
+	return Score(m.Algorithm, a, b)
+}
+
+// Match reports whether the score of a and b is above the threshold, the Words algorithm needs a score strictly above it
+// like Search always did
+func (m Matcher) Match(a, b string) bool {
+	score := m.Score(a, b)
+	if m.Algorithm == AlgorithmWords || m.Algorithm == "" {
+		return score > m.Threshold
+	}
+	return score >= m.Threshold
+}
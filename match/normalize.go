@@ -0,0 +1,67 @@
+package match
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalize returns the comparable form of s, it is NFKC normalized so full width and compatibility characters match their
+// plain form, accents of latin, greek and cyrillic letters are folded (é becomes e), the text is lower cased and
+// punctuation is replaced by single spaces so "One-Piece" and "one  piece" are the same text
+// other marks are kept, ピ and ヒ or the vowel signs of devanagari and thai stay different
+func Normalize(s string) string {
+	var b strings.Builder
+	space, fold := false, false
+	for _, r := range norm.NFD.String(norm.NFKC.String(s)) {
+		switch {
+		case unicode.Is(unicode.Mark, r):
+			// combining accents left by the decomposition are only dropped after the letters they accent
+			if fold && r >= 0x0300 && r <= 0x036f {
+				continue
+			}
+			if b.Len() > 0 && !space {
+				b.WriteRune(r)
+			}
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			fold = unicode.In(r, unicode.Latin, unicode.Greek, unicode.Cyrillic)
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			space, fold = true, false
+		}
+	}
+	return norm.NFC.String(b.String())
+}
+
+// Tokens splits the normalized text into words, CJK text has no spaces so every ideograph, kana or hangul syllable
+// is its own token
+func Tokens(s string) []string {
+	var output []string
+	for _, word := range strings.Fields(Normalize(s)) {
+		start := 0
+		runes := []rune(word)
+		for i, r := range runes {
+			if !isCJK(r) {
+				continue
+			}
+			if start < i {
+				output = append(output, string(runes[start:i]))
+			}
+			output = append(output, string(r))
+			start = i + 1
+		}
+		if start < len(runes) {
+			output = append(output, string(runes[start:]))
+		}
+	}
+	return output
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
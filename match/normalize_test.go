@@ -0,0 +1,41 @@
+package match
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	for _, c := range []struct {
+		in, want string
+	}{
+		{"One-Piece!!", "one piece"},
+		{"ＯＮＥ　ＰＩＥＣＥ", "one piece"},
+		{"Pokémon", "pokemon"},
+		{"Ελλάδα", "ελλαδα"},
+		{"ワンピース", "ワンピース"},
+		{"バカ", "バカ"},
+		{"हिन्दी", "हिन्दी"},
+		{"ภาษาไทย", "ภาษาไทย"},
+	} {
+		if got := Normalize(c.in); got != c.want {
+			t.Errorf("Normalize(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestTokens(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want []string
+	}{
+		{"One  Piece, Vol. 1", []string{"one", "piece", "vol", "1"}},
+		{"ワンピース", []string{"ワ", "ン", "ピ", "ー", "ス"}},
+		{"進撃の巨人 season2", []string{"進", "撃", "の", "巨", "人", "season2"}},
+		{"!!", nil},
+	} {
+		if got := Tokens(c.in); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Tokens(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package match
+
+import (
+	"sort"
+	"strings"
+)
+
+// Levenshtein returns the number of rune insertions, deletions and substitutions turning a into b
+func Levenshtein(a, b string) int {
+	return editDistance([]rune(a), []rune(b), false)
+}
+
+// Damerau returns the Levenshtein distance where swapping two adjacent runes counts as a single edit (optimal string alignment)
+func Damerau(a, b string) int {
+	return editDistance([]rune(a), []rune(b), true)
+}
+
+func editDistance(a, b []rune, transpositions bool) int {
+	if len(a) == 0 {
+		return len(b)
+	}
+	if len(b) == 0 {
+		return len(a)
+	}
+	// three rows are enough, the one before the previous row is only needed for transpositions
+	before, previous, current := make([]int, len(b)+1), make([]int, len(b)+1), make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(minInt(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+			if transpositions && i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				current[j] = minInt(current[j], before[j-2]+1)
+			}
+		}
+		before, previous, current = previous, current, before
+	}
+	return previous[len(b)]
+}
+
+// LevenshteinRatio turns the Levenshtein distance of the normalized texts into a similarity between 0 and 1
+func LevenshteinRatio(a, b string) float64 {
+	return distanceRatio(Normalize(a), Normalize(b), Levenshtein)
+}
+
+// DamerauRatio turns the Damerau distance of the normalized texts into a similarity between 0 and 1
+func DamerauRatio(a, b string) float64 {
+	return distanceRatio(Normalize(a), Normalize(b), Damerau)
+}
+
+func distanceRatio(a, b string, distance func(a, b string) int) float64 {
+	longest := maxInt(len([]rune(a)), len([]rune(b)))
+	if longest == 0 {
+		return 0
+	}
+	return 1 - float64(distance(a, b))/float64(longest)
+}
+
+// JaroWinkler returns the Jaro-Winkler similarity of the normalized texts, common prefixes of up to 4 runes raise the score
+// which suits names and titles where typos are more likely at the end
+func JaroWinkler(a, b string) float64 {
+	r1, r2 := []rune(Normalize(a)), []rune(Normalize(b))
+	jaro := jaro(r1, r2)
+	prefix := 0
+	for prefix < minInt(4, minInt(len(r1), len(r2))) && r1[prefix] == r2[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+func jaro(a, b []rune) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	window := maxInt(len(a), len(b))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+	matchedA, matchedB := make([]bool, len(a)), make([]bool, len(b))
+	matches := 0
+	for i := range a {
+		for j := maxInt(0, i-window); j < minInt(len(b), i+window+1); j++ {
+			if !matchedB[j] && a[i] == b[j] {
+				matchedA[i], matchedB[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+	transpositions, j := 0, 0
+	for i := range a {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[j] {
+			j++
+		}
+		if a[i] != b[j] {
+			transpositions++
+		}
+		j++
+	}
+	m := float64(matches)
+	return (m/float64(len(a)) + m/float64(len(b)) + (m-float64(transpositions)/2)/m) / 3
+}
+
+// TokenSortRatio compares the texts with their tokens sorted, so word order does not matter: "Piece One" matches "one piece"
+func TokenSortRatio(a, b string) float64 {
+	return distanceRatio(sortedTokens(a), sortedTokens(b), Levenshtein)
+}
+
+func sortedTokens(s string) string {
+	tokens := Tokens(s)
+	sort.Strings(tokens)
+	return strings.Join(tokens, " ")
+}
+
+// TokenSetRatio compares the shared tokens with each text, so a text containing all the words of the other scores 1
+// even with extra words: "One Piece" matches "One Piece (Official Colored)"
+func TokenSetRatio(a, b string) float64 {
+	setA, setB := tokenSet(a), tokenSet(b)
+	var common, onlyA, onlyB []string
+	for t := range setA {
+		if _, found := setB[t]; found {
+			common = append(common, t)
+		} else {
+			onlyA = append(onlyA, t)
+		}
+	}
+	for t := range setB {
+		if _, found := setA[t]; !found {
+			onlyB = append(onlyB, t)
+		}
+	}
+	sort.Strings(common)
+	sort.Strings(onlyA)
+	sort.Strings(onlyB)
+	base := strings.Join(common, " ")
+	withA := strings.TrimSpace(base + " " + strings.Join(onlyA, " "))
+	withB := strings.TrimSpace(base + " " + strings.Join(onlyB, " "))
+	best := distanceRatio(withA, withB, Levenshtein)
+	if base != "" {
+		best = maxFloat(best, maxFloat(distanceRatio(base, withA, Levenshtein), distanceRatio(base, withB, Levenshtein)))
+	}
+	return best
+}
+
+func tokenSet(s string) map[string]struct{} {
+	set := map[string]struct{}{}
+	for _, t := range Tokens(s) {
+		set[t] = struct{}{}
+	}
+	return set
+}
+
+// Trigram returns the Jaccard similarity of the rune trigrams of the normalized texts, every token is padded with spaces
+// so short words still produce trigrams
+func Trigram(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	shared := 0
+	for g := range ta {
+		if _, found := tb[g]; found {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+func trigrams(s string) map[string]struct{} {
+	set := map[string]struct{}{}
+	for _, t := range Tokens(s) {
+		r := []rune("  " + t + " ")
+		for i := 0; i+3 <= len(r); i++ {
+			set[string(r[i:i+3])] = struct{}{}
+		}
+	}
+	return set
+}
+
+// Words is a word containment score, every word of the longer text scores 1 when the other text has the same word,
+// or the share of the word covered when one contains the other, and the scores are averaged
+// the words come from Tokens so unlike the score Search used before, accents, case and punctuation are ignored and CJK
+// text is compared per character, scores of the same texts can differ from older releases
+func Words(a, b string) float64 {
+	long, short := Tokens(a), Tokens(b)
+	if len(long) == 0 || len(short) == 0 {
+		return 0
+	}
+	if len(Normalize(b)) > len(Normalize(a)) {
+		long, short = short, long
+	}
+	sum := 0.0
+	for _, l := range long {
+		best := 0.0
+		for _, s := range short {
+			if l == s {
+				best = 1
+				break
+			}
+			small, large := s, l
+			if len(small) > len(large) {
+				small, large = large, small
+			}
+			if !strings.Contains(large, small) {
+				continue
+			}
+			if p := float64(len(small)) / float64(len(large)); p > best {
+				best = p
+			}
+		}
+		sum += best
+	}
+	return sum / float64(len(long))
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,55 @@
+package match
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	for _, c := range []struct {
+		a, b                 string
+		levenshtein, damerau int
+	}{
+		{"kitten", "sitting", 3, 3},
+		{"ca", "ac", 2, 1},
+		{"abcd", "acbd", 2, 1},
+		{"", "abc", 3, 3},
+		{"ピース", "ビース", 1, 1},
+	} {
+		if got := Levenshtein(c.a, c.b); got != c.levenshtein {
+			t.Errorf("Levenshtein(%q, %q) = %d, want %d", c.a, c.b, got, c.levenshtein)
+		}
+		if got := Damerau(c.a, c.b); got != c.damerau {
+			t.Errorf("Damerau(%q, %q) = %d, want %d", c.a, c.b, got, c.damerau)
+		}
+	}
+}
+
+func TestScores(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		score func(a, b string) float64
+		a, b  string
+		want  float64
+	}{
+		{"levenshtein", LevenshteinRatio, "One Piece", "one-piece", 1},
+		{"damerau", DamerauRatio, "abcd", "acbd", 0.75},
+		{"jaro-winkler", JaroWinkler, "MARTHA", "MARHTA", 0.9611},
+		{"jaro-winkler", JaroWinkler, "DIXON", "DICKSONX", 0.8133},
+		{"jaro-winkler", JaroWinkler, "abc", "xyz", 0},
+		{"token-sort", TokenSortRatio, "Piece One", "one piece", 1},
+		{"token-set", TokenSetRatio, "One Piece", "One Piece Volume 1", 1},
+		{"token-set", TokenSetRatio, "a b", "c d", 0.3333},
+		{"trigram", Trigram, "one piece", "One Piece", 1},
+		{"trigram", Trigram, "night", "nacht", 0.2},
+		{"trigram", Trigram, "abc", "xyz", 0},
+		{"words", Words, "one piece", "one piece volume", 0.6667},
+		{"words", Words, "one", "onepiece", 0.375},
+		{"words", Words, "ワンピース", "ワンビース", 0.8},
+		{"words", Words, "a", "b", 0},
+	} {
+		if got := c.score(c.a, c.b); math.Abs(got-c.want) > 0.001 {
+			t.Errorf("%s(%q, %q) = %.4f, want %.4f", c.name, c.a, c.b, got, c.want)
+		}
+	}
+}
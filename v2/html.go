@@ -3,18 +3,23 @@ package v2
 import (
 	"fmt"
 	"net/url"
-	"regexp"
 	"strings"
+
+	"github.com/Seann-Moser/WebParser/match"
 )
 
 type HtmlData struct {
 	ID         string            `json:"id"`
+	Path       string            `json:"path"`
 	Parent     *HtmlData         `json:"-"`
 	Tag        string            `json:"tag"`
 	Attributes map[string]string `json:"attributes"`
 	TextData   string            `json:"text_data"`
 	Child      []*HtmlData       `json:"-"`
 	Sibling    []*HtmlData       `json:"-"`
+
+	index   map[string]*HtmlData
+	content []contentPart
 }
 
 // Flatten is used to grab all siblings and children and flatten them into a single object
@@ -24,9 +29,13 @@ func (h *HtmlData) Flatten(tags []string, skipId []string) *HtmlData {
 	if tags == nil || isInArray(h.Tag, tags) {
 		flatD = &HtmlData{
 			Tag:        h.Tag,
-			Attributes: h.Attributes,
+			Attributes: make(map[string]string, len(h.Attributes)),
 			TextData:   h.TextData,
 		}
+		// the attributes are joined below so they are copied to leave h as it is
+		for k, v := range h.Attributes {
+			flatD.Attributes[k] = v
+		}
 	}
 	for _, c := range h.Child {
 		tmp := c.Flatten(tags, skipId)
@@ -73,6 +82,84 @@ func (h *HtmlData) Flatten(tags []string, skipId []string) *HtmlData {
 	return flatD
 }
 
+// Clone returns a deep copy of the element with all of its children and siblings, the copy has no parent
+// ids and paths are kept so the copy can be matched back to the original
+func (h *HtmlData) Clone() *HtmlData {
+	return h.clone(nil)
+}
+
+func (h *HtmlData) clone(parent *HtmlData) *HtmlData {
+	c := &HtmlData{
+		ID:         h.ID,
+		Path:       h.Path,
+		Parent:     parent,
+		Tag:        h.Tag,
+		Attributes: make(map[string]string, len(h.Attributes)),
+		TextData:   h.TextData,
+	}
+	for k, v := range h.Attributes {
+		c.Attributes[k] = v
+	}
+	copies := map[*HtmlData]*HtmlData{}
+	for _, child := range h.Child {
+		cp := child.clone(c)
+		copies[child] = cp
+		c.Child = append(c.Child, cp)
+	}
+	for _, s := range h.Sibling {
+		cp := s.clone(c)
+		copies[s] = cp
+		c.Sibling = append(c.Sibling, cp)
+	}
+	if h.content != nil {
+		c.content = make([]contentPart, 0, len(h.content))
+		for _, p := range h.content {
+			if p.node != nil {
+				p.node = copies[p.node]
+				if p.node == nil {
+					continue
+				}
+			}
+			c.content = append(c.content, p)
+		}
+	}
+	return c
+}
+
+// Remove deletes every child and sibling at any depth that remove returns true for, removed elements are not visited
+func (h *HtmlData) Remove(remove func(d *HtmlData) bool) {
+	removed := map[*HtmlData]struct{}{}
+	keep := func(list []*HtmlData) []*HtmlData {
+		var kept []*HtmlData
+		for _, d := range list {
+			if remove(d) {
+				removed[d] = struct{}{}
+				continue
+			}
+			kept = append(kept, d)
+		}
+		return kept
+	}
+	h.Child = keep(h.Child)
+	h.Sibling = keep(h.Sibling)
+	if len(removed) > 0 && h.content != nil {
+		var content []contentPart
+		for _, p := range h.content {
+			if _, found := removed[p.node]; p.node != nil && found {
+				continue
+			}
+			content = append(content, p)
+		}
+		h.content = content
+	}
+	for _, c := range h.Child {
+		c.Remove(remove)
+	}
+	for _, s := range h.Sibling {
+		s.Remove(remove)
+	}
+}
+
 func isInArray(v string, v1 []string) bool {
 	for _, d := range v1 {
 		if strings.EqualFold(d, v) {
@@ -98,81 +185,46 @@ func (h *HtmlData) GetTags(tags []string) []*HtmlData {
 	return d
 }
 
+// Elements returns the element and every element inside it in document order
+// unlike GetTags, self-closing elements like img are kept in their place between the other children
+func (h *HtmlData) Elements() []*HtmlData {
+	d := []*HtmlData{h}
+	for _, p := range h.parts() {
+		if p.node != nil {
+			d = append(d, p.node.Elements()...)
+		}
+	}
+	return d
+}
+
+// Children returns the elements directly inside h in document order, self-closing elements included
+func (h *HtmlData) Children() []*HtmlData {
+	return childElements(h)
+}
+
+// NextSiblings returns the elements after h inside its parent in document order
+func (h *HtmlData) NextSiblings() []*HtmlData {
+	siblings := childElements(h.Parent)
+	for i, s := range siblings {
+		if s == h {
+			return siblings[i+1:]
+		}
+	}
+	return nil
+}
+
 // Search will go through a site and find all tags with the attribute key-value pair
 // the attributes value is a regex expression
 // EX: "href":".*\.png$" - will match to all href attributes ending with .png
+// keys starting with tag, text or * also fuzzy match the value with match.Default
+// elements matching several keys are returned once in document order, use Find for explicit modes, AND and NOT
 func (h *HtmlData) Search(tags []string, attributes map[string]string, skipId []string) []*HtmlData {
-	var output []*HtmlData
-	if !isInArray(h.ID, skipId) {
-		if tags == nil || isInArray(h.Tag, tags) || len(tags) == 0 {
-			if len(attributes) == 0 {
-				output = append(output, h)
-			}
-			for k, v := range attributes {
-				if strings.HasPrefix(k, "tag") || strings.HasPrefix(k, "*") {
-					foundValue, err := regexp.MatchString(v, h.Tag)
-					if err != nil {
-						if strings.EqualFold(v, h.Tag) {
-							output = append(output, h)
-							continue
-						}
-					}
-
-					if foundValue || Match(v, h.Tag) > 0.5 {
-						output = append(output, h)
-						continue
-					}
-				}
-				if strings.HasPrefix(k, "text") || strings.HasPrefix(k, "*") {
-					foundValue, err := regexp.MatchString(v, h.TextData)
-					if err != nil {
-						if strings.EqualFold(v, h.TextData) {
-							output = append(output, h)
-							continue
-						}
-					}
-
-					if foundValue || Match(v, h.TextData) > 0.5 {
-						output = append(output, h)
-						continue
-					}
-				}
-				if strings.HasPrefix(k, "*") {
-					for _, av := range h.Attributes {
-						foundValue, err := regexp.MatchString(v, av)
-						if err != nil {
-							if strings.EqualFold(v, av) {
-								output = append(output, h)
-								break
-							}
-						}
+	return h.SearchWith(tags, attributes, skipId, match.Default)
+}
 
-						if foundValue || Match(v, av) > 0.5 {
-							output = append(output, h)
-							break
-						}
-					}
-				}
-				if attribute, found := h.Attributes[k]; found {
-					foundValue, err := regexp.MatchString(v, attribute)
-					if err != nil {
-						if strings.EqualFold(v, attribute) {
-							output = append(output, h)
-						}
-					}
-					if foundValue {
-						output = append(output, h)
-					}
-				}
-			}
-		}
-	}
-	for _, c := range h.Child {
-		output = append(output, c.Search(tags, attributes, skipId)...)
-	}
-	for _, s := range h.Sibling {
-		output = append(output, s.Search(tags, attributes, skipId)...)
-	}
+// SearchWith is Search with the algorithm and threshold used to fuzzy match tag, text and * keys
+func (h *HtmlData) SearchWith(tags []string, attributes map[string]string, skipId []string, m match.Matcher) []*HtmlData {
+	output, _ := h.Find(SearchQuery(tags, attributes, skipId, m))
 	return output
 }
 
@@ -0,0 +1,101 @@
+package v2
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// IDMode controls how ids are given to nodes when a page is processed
+type IDMode int
+
+const (
+	// RandomIDs gives every node a new uuid, ids will differ between two parses of the same page
+	RandomIDs IDMode = iota
+	// PathIDs derives the id from the structural path of the node, ie /html[0]/body[0]/div[2]
+	// the same page will always produce the same ids
+	PathIDs
+	// ContentIDs derives the id from the structural path and a hash of the node's own tag, attributes and text
+	// so a node keeps its id only while both its position and content are unchanged
+	ContentIDs
+)
+
+// AssignIDs walks the tree setting the Path and ID of every node and rebuilds the id index used by ByID
+func (h *HtmlData) AssignIDs(mode IDMode) {
+	h.assignIDs(mode, "")
+	h.buildIndex()
+}
+
+func (h *HtmlData) assignIDs(mode IDMode, path string) {
+	h.Path = path
+	switch mode {
+	case PathIDs:
+		h.ID = hashID(path)
+	case ContentIDs:
+		h.ID = hashID(path + "|" + h.contentHash())
+	default:
+		h.ID = uuid.New().String()
+	}
+	counts := map[string]int{}
+	for _, c := range h.Child {
+		c.assignIDs(mode, childPath(path, c.Tag, counts))
+	}
+	for _, s := range h.Sibling {
+		s.assignIDs(mode, childPath(path, s.Tag, counts))
+	}
+}
+
+// childPath builds the path of a node from its parent path, the index counts same tag nodes only
+// so adding a different element before it does not change the path
+func childPath(parentPath, tag string, counts map[string]int) string {
+	tag = strings.ToLower(tag)
+	p := fmt.Sprintf("%s/%s[%d]", parentPath, tag, counts[tag])
+	counts[tag]++
+	return p
+}
+
+func (h *HtmlData) contentHash() string {
+	keys := make([]string, 0, len(h.Attributes))
+	for k := range h.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	b := strings.Builder{}
+	b.WriteString(h.Tag)
+	for _, k := range keys {
+		b.WriteString("\x00" + k + "=" + h.Attributes[k])
+	}
+	b.WriteString("\x00" + h.TextData)
+	return hashID(b.String())
+}
+
+func hashID(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:16])
+}
+
+// ByID returns the node with the given id from this node or any of its children and siblings
+// the index is rebuilt when an id is not found so changes made to the tree are picked up
+func (h *HtmlData) ByID(id string) *HtmlData {
+	if d, found := h.index[id]; found && d.ID == id {
+		return d
+	}
+	h.buildIndex()
+	return h.index[id]
+}
+
+func (h *HtmlData) buildIndex() {
+	h.index = map[string]*HtmlData{}
+	for _, d := range h.GetTags(nil) {
+		if d.ID == "" {
+			continue
+		}
+		if _, found := h.index[d.ID]; !found {
+			h.index[d.ID] = d
+		}
+	}
+}
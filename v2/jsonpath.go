@@ -0,0 +1,430 @@
+package v2
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pathStep is one selector of a JSONPath expression
+type pathStep struct {
+	recursive bool
+	wildcard  bool
+	names     []string
+	indexes   []int
+	slice     bool
+	start     *int
+	end       *int
+	filter    *pathFilter
+}
+
+// pathFilter is a [?(@.key op value)] selector, op is empty when only the presence of the key is checked
+type pathFilter struct {
+	path  []*pathStep
+	op    string
+	value interface{}
+	regex *regexp.Regexp
+}
+
+var filterOps = []string{"==", "!=", "<=", ">=", "=~", "<", ">"}
+
+// JSONPath returns the values of v matching a JSONPath expression, v is usually a ScriptData value or a json.Unmarshal result
+// supported selectors are $ (optional), .name, ['name'], ['a','b'], [0], [-1], [0,2], [start:end], *, ..name for recursive descent
+// and filters like [?(@.type == 'image')], [?(@.width > 100)], [?(@.url =~ '\.jpg$')] or [?(@.id)]
+// object keys are visited in sorted order by * and .. since Go maps have no order
+func JSONPath(v interface{}, path string) ([]interface{}, error) {
+	steps, err := compilePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return evaluatePath(v, steps), nil
+}
+
+func compilePath(path string) ([]*pathStep, error) {
+	path = strings.TrimSpace(path)
+	path = strings.TrimPrefix(path, "$")
+	var steps []*pathStep
+	for i := 0; i < len(path); {
+		step := &pathStep{}
+		switch {
+		case strings.HasPrefix(path[i:], ".."):
+			step.recursive = true
+			i += 2
+			if i < len(path) && path[i] == '[' {
+				end, err := parseBracket(path, i, step)
+				if err != nil {
+					return nil, err
+				}
+				i = end
+				steps = append(steps, step)
+				continue
+			}
+			i = parseName(path, i, step)
+		case path[i] == '.':
+			i = parseName(path, i+1, step)
+		case path[i] == '[':
+			end, err := parseBracket(path, i, step)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+		default:
+			// a leading name without $, ie data.items
+			if len(steps) > 0 {
+				return nil, fmt.Errorf("jsonpath %q: unexpected %q at %d", path, path[i], i)
+			}
+			i = parseName(path, i, step)
+		}
+		if !step.wildcard && step.names == nil && step.indexes == nil && !step.slice && step.filter == nil {
+			return nil, fmt.Errorf("jsonpath %q: empty selector at %d", path, i)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func parseName(path string, i int, step *pathStep) int {
+	end := i
+	for end < len(path) && path[end] != '.' && path[end] != '[' {
+		end++
+	}
+	name := path[i:end]
+	if name == "*" {
+		step.wildcard = true
+	} else if name != "" {
+		step.names = []string{name}
+	}
+	return end
+}
+
+// parseBracket reads the selector between [ and ] starting at i and returns the offset after the ]
+func parseBracket(path string, i int, step *pathStep) (int, error) {
+	end := closingBracket(path, i)
+	if end < 0 {
+		return 0, fmt.Errorf("jsonpath %q: unterminated [ at %d", path, i)
+	}
+	inner := strings.TrimSpace(path[i+1 : end])
+	switch {
+	case inner == "*":
+		step.wildcard = true
+	case strings.HasPrefix(inner, "?"):
+		f, err := compileFilter(strings.TrimSpace(inner[1:]))
+		if err != nil {
+			return 0, fmt.Errorf("jsonpath %q: %v", path, err)
+		}
+		step.filter = f
+	case strings.HasPrefix(inner, "'") || strings.HasPrefix(inner, `"`):
+		for _, part := range splitOutsideQuotes(inner, ',') {
+			v, err := ParseLiteral(strings.TrimSpace(part))
+			name, ok := v.(string)
+			if err != nil || !ok {
+				return 0, fmt.Errorf("jsonpath %q: invalid name %s", path, part)
+			}
+			step.names = append(step.names, name)
+		}
+	case strings.Contains(inner, ":"):
+		step.slice = true
+		parts := strings.SplitN(inner, ":", 3)
+		for k, target := range []**int{&step.start, &step.end} {
+			if s := strings.TrimSpace(parts[k]); s != "" {
+				n, err := strconv.Atoi(s)
+				if err != nil {
+					return 0, fmt.Errorf("jsonpath %q: invalid slice %s", path, inner)
+				}
+				*target = &n
+			}
+		}
+	default:
+		for _, part := range strings.Split(inner, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return 0, fmt.Errorf("jsonpath %q: invalid index %s", path, part)
+			}
+			step.indexes = append(step.indexes, n)
+		}
+	}
+	return end + 1, nil
+}
+
+// closingBracket returns the offset of the ] closing the [ at i, brackets inside quotes and filters are skipped
+func closingBracket(path string, i int) int {
+	depth := 0
+	var quote byte
+	for j := i; j < len(path); j++ {
+		c := path[j]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				j++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+func splitOutsideQuotes(s string, sep byte) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for j := 0; j < len(s); j++ {
+		c := s[j]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				j++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == sep:
+			parts = append(parts, s[start:j])
+			start = j + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func compileFilter(expr string) (*pathFilter, error) {
+	expr = strings.TrimSpace(expr)
+	if !strings.HasPrefix(expr, "(") || !strings.HasSuffix(expr, ")") {
+		return nil, fmt.Errorf("filter %q must be written as ?(...)", expr)
+	}
+	expr = strings.TrimSpace(expr[1 : len(expr)-1])
+	if !strings.HasPrefix(expr, "@") {
+		return nil, fmt.Errorf("filter %q must start with @", expr)
+	}
+	f := &pathFilter{}
+	left := expr[1:]
+	for _, op := range filterOps {
+		if i := indexOutsideQuotes(left, op); i >= 0 {
+			f.op = op
+			v, err := ParseLiteral(strings.TrimSpace(left[i+len(op):]))
+			if err != nil {
+				return nil, fmt.Errorf("filter %q: %v", expr, err)
+			}
+			f.value = v
+			if op == "=~" {
+				pattern, _ := v.(string)
+				if f.regex, err = regexp.Compile(pattern); err != nil {
+					return nil, fmt.Errorf("filter %q: %v", expr, err)
+				}
+			}
+			left = strings.TrimSpace(left[:i])
+			break
+		}
+	}
+	steps, err := compilePath(left)
+	if err != nil {
+		return nil, err
+	}
+	f.path = steps
+	return f, nil
+}
+
+func indexOutsideQuotes(s, sub string) int {
+	var quote byte
+	for j := 0; j < len(s); j++ {
+		c := s[j]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				j++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case strings.HasPrefix(s[j:], sub):
+			return j
+		}
+	}
+	return -1
+}
+
+func evaluatePath(v interface{}, steps []*pathStep) []interface{} {
+	current := []interface{}{v}
+	for _, step := range steps {
+		var next []interface{}
+		for _, c := range current {
+			if step.recursive {
+				for _, d := range descendants(c) {
+					next = append(next, step.apply(d)...)
+				}
+				continue
+			}
+			next = append(next, step.apply(c)...)
+		}
+		current = next
+	}
+	return current
+}
+
+func (s *pathStep) apply(v interface{}) []interface{} {
+	var output []interface{}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		switch {
+		case s.wildcard:
+			for _, k := range sortedKeys(t) {
+				output = append(output, t[k])
+			}
+		case s.filter != nil:
+			for _, k := range sortedKeys(t) {
+				if s.filter.match(t[k]) {
+					output = append(output, t[k])
+				}
+			}
+		default:
+			for _, name := range s.names {
+				if value, found := t[name]; found {
+					output = append(output, value)
+				}
+			}
+		}
+	case []interface{}:
+		switch {
+		case s.wildcard:
+			output = append(output, t...)
+		case s.filter != nil:
+			for _, item := range t {
+				if s.filter.match(item) {
+					output = append(output, item)
+				}
+			}
+		case s.slice:
+			start, end := 0, len(t)
+			if s.start != nil {
+				start = clampIndex(*s.start, len(t))
+			}
+			if s.end != nil {
+				end = clampIndex(*s.end, len(t))
+			}
+			for i := start; i < end; i++ {
+				output = append(output, t[i])
+			}
+		default:
+			for _, i := range s.indexes {
+				if i < 0 {
+					i += len(t)
+				}
+				if i >= 0 && i < len(t) {
+					output = append(output, t[i])
+				}
+			}
+		}
+	}
+	return output
+}
+
+func clampIndex(i, length int) int {
+	if i < 0 {
+		i += length
+	}
+	if i < 0 {
+		return 0
+	}
+	if i > length {
+		return length
+	}
+	return i
+}
+
+// descendants returns the value and every value nested in it, depth first
+func descendants(v interface{}) []interface{} {
+	output := []interface{}{v}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedKeys(t) {
+			output = append(output, descendants(t[k])...)
+		}
+	case []interface{}:
+		for _, item := range t {
+			output = append(output, descendants(item)...)
+		}
+	}
+	return output
+}
+
+func (f *pathFilter) match(v interface{}) bool {
+	found := evaluatePath(v, f.path)
+	if len(found) == 0 {
+		return false
+	}
+	if f.op == "" {
+		return true
+	}
+	left := found[0]
+	switch f.op {
+	case "=~":
+		s, ok := left.(string)
+		return ok && f.regex.MatchString(s)
+	case "==":
+		return literalEqual(left, f.value)
+	case "!=":
+		return !literalEqual(left, f.value)
+	}
+	if l, ok := left.(float64); ok {
+		if r, ok := f.value.(float64); ok {
+			switch f.op {
+			case "<":
+				return l < r
+			case "<=":
+				return l <= r
+			case ">":
+				return l > r
+			case ">=":
+				return l >= r
+			}
+		}
+	}
+	if l, ok := left.(string); ok {
+		if r, ok := f.value.(string); ok {
+			switch f.op {
+			case "<":
+				return l < r
+			case "<=":
+				return l <= r
+			case ">":
+				return l > r
+			case ">=":
+				return l >= r
+			}
+		}
+	}
+	return false
+}
+
+func literalEqual(a, b interface{}) bool {
+	switch a.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	switch b.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	return a == b
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,356 @@
+package v2
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	markdownEscaper    = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", `\<`)
+	markdownBlankLines = regexp.MustCompile(`\n{3,}`)
+	markdownSpaces     = regexp.MustCompile(`[ \t]+`)
+	markdownWhitespace = regexp.MustCompile(`[ \t\n\r\f]+`)
+	// markdownOrdered is text that starts like an ordered list item, ie "1. " or "2)"
+	markdownOrdered = regexp.MustCompile(`^(\d{1,9})([.)])(\s|$)`)
+)
+
+// ToMarkdown converts the element and everything in it into CommonMark with GFM tables and strikethrough
+// links and images are made absolute using baseLink the same way FindLinks builds them
+func (h *HtmlData) ToMarkdown(baseLink string) string {
+	m := &markdownConverter{baseLink: baseLink}
+	var out string
+	if _, block := textBlockElements[strings.ToLower(h.Tag)]; block || h.Tag == "" {
+		out = m.block(h)
+	} else {
+		out = m.paragraph(m.inline(h))
+	}
+	out = markdownBlankLines.ReplaceAllString(out, "\n\n")
+	return strings.TrimSpace(out) + "\n"
+}
+
+type markdownConverter struct {
+	baseLink string
+}
+
+// blocks renders the content of a container, runs of inline content become paragraphs
+// blocks are separated by a blank line, when tight a nested list directly follows the line before it
+func (m *markdownConverter) blocks(h *HtmlData, tight bool) string {
+	out := strings.Builder{}
+	previousList := false
+	add := func(b string, list bool) {
+		if out.Len() > 0 {
+			if tight && (list || previousList) {
+				out.WriteString("\n")
+			} else {
+				out.WriteString("\n\n")
+			}
+		}
+		out.WriteString(b)
+		previousList = list
+	}
+	inline := strings.Builder{}
+	flush := func() {
+		if p := m.paragraph(inline.String()); p != "" {
+			add(p, false)
+		}
+		inline.Reset()
+	}
+	for _, p := range h.parts() {
+		if p.node == nil {
+			inline.WriteString(m.text(p.text))
+			continue
+		}
+		if p.node.isHidden() {
+			continue
+		}
+		tag := strings.ToLower(p.node.Tag)
+		if _, block := textBlockElements[tag]; block {
+			flush()
+			if b := m.block(p.node); strings.TrimSpace(b) != "" {
+				add(b, tag == "ul" || tag == "ol")
+			}
+			continue
+		}
+		inline.WriteString(m.inline(p.node))
+	}
+	flush()
+	return out.String()
+}
+
+// text escapes source text and collapses its whitespace so only <br> produces a line break
+func (m *markdownConverter) text(s string) string {
+	return markdownWhitespace.ReplaceAllString(markdownEscaper.Replace(s), " ")
+}
+
+// paragraph collapses the whitespace of inline markdown, line breaks left in it come from <br>
+// text starting like a heading, a list, a quote or a rule is escaped so every line stays paragraph text
+func (m *markdownConverter) paragraph(s string) string {
+	lines := strings.Split(s, "\n")
+	var out []string
+	for _, l := range lines {
+		l = strings.TrimSpace(markdownSpaces.ReplaceAllString(l, " "))
+		if l != "" {
+			out = append(out, escapeBlockStart(l))
+		}
+	}
+	return strings.Join(out, "\\\n")
+}
+
+// escapeBlockStart escapes the markers that would turn the start of a line into a block, ie "# not a heading"
+func escapeBlockStart(l string) string {
+	switch {
+	case strings.ContainsRune("#-+>=", rune(l[0])), strings.HasPrefix(l, "~~~"):
+		// two tildes are a strikethrough, three a code fence
+		return `\` + l
+	}
+	return markdownOrdered.ReplaceAllString(l, `$1\$2$3`)
+}
+
+func (m *markdownConverter) block(h *HtmlData) string {
+	tag := strings.ToLower(h.Tag)
+	switch tag {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level, _ := strconv.Atoi(tag[1:])
+		text := strings.ReplaceAll(m.paragraph(m.inline(h)), "\\\n", " ")
+		if text == "" {
+			return ""
+		}
+		return strings.Repeat("#", level) + " " + text
+	case "hr":
+		return "---"
+	case "pre":
+		return m.codeBlock(h)
+	case "blockquote":
+		return prefixLines(m.blocks(h, false), "> ", "> ")
+	case "ul", "ol", "menu", "dir":
+		return m.list(h, tag == "ol")
+	case "table":
+		return m.table(h)
+	case "li":
+		return m.listItem(h, "- ")
+	}
+	return m.blocks(h, false)
+}
+
+func (m *markdownConverter) inline(h *HtmlData) string {
+	tag := strings.ToLower(h.Tag)
+	switch tag {
+	case "br":
+		return "\n"
+	case "img":
+		src := m.link(h, []string{"src", "data-src", "data-lazy-src", "data-original"})
+		if src == "" {
+			return ""
+		}
+		return "![" + markdownEscaper.Replace(h.Attributes["alt"]) + "](" + markdownURL(src) + markdownTitle(h) + ")"
+	case "code", "kbd", "samp", "tt":
+		return codeSpan(rawText(h))
+	}
+
+	content := strings.Builder{}
+	for _, p := range h.parts() {
+		if p.node == nil {
+			content.WriteString(m.text(p.text))
+			continue
+		}
+		if p.node.isHidden() {
+			continue
+		}
+		content.WriteString(m.inline(p.node))
+	}
+	text := content.String()
+
+	switch tag {
+	case "strong", "b":
+		return wrapInline(text, "**")
+	case "em", "i", "cite", "dfn":
+		return wrapInline(text, "*")
+	case "del", "s", "strike":
+		return wrapInline(text, "~~")
+	case "a":
+		href := m.link(h, []string{"href"})
+		if href == "" || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+			return text
+		}
+		if strings.TrimSpace(text) == "" {
+			return text
+		}
+		return "[" + strings.TrimSpace(text) + "](" + markdownURL(href) + markdownTitle(h) + ")"
+	}
+	return text
+}
+
+// link builds an absolute link with FindLinks, links FindLinks can not build are resolved against the base link
+func (m *markdownConverter) link(h *HtmlData, attributes []string) string {
+	l, err := h.FindLinks(m.baseLink, attributes)
+	if err == nil && l != "" {
+		return l
+	}
+	for _, a := range attributes {
+		raw := strings.TrimSpace(h.Attributes[a])
+		if raw == "" || strings.Contains(raw, "base64") {
+			continue
+		}
+		base, err := url.Parse(m.baseLink)
+		if err != nil {
+			return raw
+		}
+		ref, err := url.Parse(raw)
+		if err != nil {
+			return raw
+		}
+		return base.ResolveReference(ref).String()
+	}
+	return ""
+}
+
+func (m *markdownConverter) list(h *HtmlData, ordered bool) string {
+	var items []string
+	count := 1
+	if start, err := strconv.Atoi(h.Attributes["start"]); err == nil && ordered {
+		count = start
+	}
+	for _, p := range h.parts() {
+		if p.node == nil || p.node.isHidden() {
+			continue
+		}
+		if !strings.EqualFold(p.node.Tag, "li") {
+			if b := m.block(p.node); b != "" {
+				items = append(items, b)
+			}
+			continue
+		}
+		marker := "- "
+		if ordered {
+			marker = strconv.Itoa(count) + ". "
+			count++
+		}
+		items = append(items, m.listItem(p.node, marker))
+	}
+	return strings.Join(items, "\n")
+}
+
+func (m *markdownConverter) listItem(h *HtmlData, marker string) string {
+	content := m.blocks(h, true)
+	return prefixLines(content, marker, strings.Repeat(" ", len(marker)))
+}
+
+func (m *markdownConverter) codeBlock(h *HtmlData) string {
+	language := ""
+	for _, c := range h.GetTags([]string{"code"}) {
+		for _, class := range strings.Fields(c.Attributes["class"]) {
+			if strings.HasPrefix(class, "language-") {
+				language = strings.TrimPrefix(class, "language-")
+			} else if strings.HasPrefix(class, "lang-") {
+				language = strings.TrimPrefix(class, "lang-")
+			}
+		}
+	}
+	code := strings.Trim(rawText(h), "\n")
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + language + "\n" + code + "\n" + fence
+}
+
+func (m *markdownConverter) table(h *HtmlData) string {
+	tables := tableFrom(h, m.baseLink, func(c *HtmlData) string {
+		return strings.ReplaceAll(m.paragraph(m.inline(c)), "\\\n", "<br>")
+	})
+	if len(tables) == 0 || len(tables[0].Headers)+len(tables[0].Rows) == 0 {
+		return ""
+	}
+	t := tables[0]
+	rows := t.Rows
+	header := t.Headers
+	if len(header) == 0 {
+		header, rows = cellText(rows[0]), rows[1:]
+	}
+	cells := func(r []string) string {
+		escaped := make([]string, len(r))
+		for i, c := range r {
+			escaped[i] = strings.ReplaceAll(c, "|", `\|`)
+		}
+		return "| " + strings.Join(escaped, " | ") + " |"
+	}
+	lines := []string{cells(header), "|" + strings.Repeat(" --- |", len(header))}
+	for _, r := range rows {
+		lines = append(lines, cells(cellText(r)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// rawText returns the text of the element and everything in it without changing any whitespace
+func rawText(h *HtmlData) string {
+	b := strings.Builder{}
+	for _, p := range h.parts() {
+		if p.node == nil {
+			b.WriteString(p.text)
+		} else if strings.EqualFold(p.node.Tag, "br") {
+			b.WriteString("\n")
+		} else {
+			b.WriteString(rawText(p.node))
+		}
+	}
+	return b.String()
+}
+
+func codeSpan(code string) string {
+	code = strings.ReplaceAll(code, "\n", " ")
+	if code == "" {
+		return ""
+	}
+	fence := "`"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+		return fence + " " + code + " " + fence
+	}
+	return fence + code + fence
+}
+
+// wrapInline puts the markers around the text keeping surrounding whitespace outside so the emphasis stays valid
+func wrapInline(text, marker string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	start := text[:strings.Index(text, trimmed)]
+	end := text[len(start)+len(trimmed):]
+	return start + marker + trimmed + marker + end
+}
+
+func markdownURL(u string) string {
+	if strings.ContainsAny(u, " ()") {
+		return "<" + u + ">"
+	}
+	return u
+}
+
+func markdownTitle(h *HtmlData) string {
+	title := h.Attributes["title"]
+	if title == "" {
+		return ""
+	}
+	return ` "` + strings.ReplaceAll(title, `"`, `\"`) + `"`
+}
+
+func prefixLines(s, first, rest string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		p := rest
+		if i == 0 {
+			p = first
+		}
+		if l == "" {
+			lines[i] = strings.TrimRight(p, " ")
+			continue
+		}
+		lines[i] = p + l
+	}
+	return strings.Join(lines, "\n")
+}
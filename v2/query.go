@@ -0,0 +1,337 @@
+package v2
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Seann-Moser/WebParser/match"
+)
+
+// MatchMode is how a Rule compares its value
+type MatchMode string
+
+const (
+	ModeExact    MatchMode = "exact"
+	ModePrefix   MatchMode = "prefix"
+	ModeSuffix   MatchMode = "suffix"
+	ModeContains MatchMode = "contains"
+	ModeRegex    MatchMode = "regex"
+	// ModeFuzzy scores the value with Rule.Matcher, see the match package
+	ModeFuzzy MatchMode = "fuzzy"
+	// ModeExists only checks that the attribute is set, the value is ignored
+	ModeExists MatchMode = "exists"
+)
+
+// Target is the part of an element a Rule looks at
+type Target string
+
+const (
+	TargetTag  Target = "tag"
+	TargetText Target = "text"
+	// TargetAttribute looks at the attribute named by Rule.Attribute
+	TargetAttribute Target = "attribute"
+	// TargetAnyAttribute matches when any attribute of the element matches
+	TargetAnyAttribute Target = "any_attribute"
+)
+
+// Condition is a test on a single element, Rule is the only leaf, And, Or and Not combine conditions
+type Condition interface {
+	compile() error
+	match(h *HtmlData) bool
+}
+
+// Rule compares a part of an element with a value
+type Rule struct {
+	Target    Target    `json:"target"`
+	Attribute string    `json:"attribute,omitempty"`
+	Mode      MatchMode `json:"mode"`
+	Value     string    `json:"value"`
+	// IgnoreCase applies to the exact, prefix, suffix, contains and regex modes
+	IgnoreCase bool `json:"ignore_case"`
+	// Matcher is the algorithm and threshold of ModeFuzzy, match.Default when the algorithm is empty
+	Matcher match.Matcher `json:"matcher"`
+
+	// the pattern is compiled once so queries sharing the rule can run at the same time
+	once sync.Once
+	re   *regexp.Regexp
+	err  error
+}
+
+// Tag matches the tag name of the element, compared case insensitive like html does
+func Tag(mode MatchMode, value string) *Rule {
+	return &Rule{Target: TargetTag, Mode: mode, Value: value, IgnoreCase: true}
+}
+
+// Text matches the TextData of the element
+func Text(mode MatchMode, value string) *Rule {
+	return &Rule{Target: TargetText, Mode: mode, Value: value}
+}
+
+// Attr matches the attribute name of the element, elements without the attribute never match
+func Attr(name string, mode MatchMode, value string) *Rule {
+	return &Rule{Target: TargetAttribute, Attribute: name, Mode: mode, Value: value}
+}
+
+// AnyAttr matches when any attribute of the element matches
+func AnyAttr(mode MatchMode, value string) *Rule {
+	return &Rule{Target: TargetAnyAttribute, Mode: mode, Value: value}
+}
+
+// Fold sets IgnoreCase and returns the rule
+func (r *Rule) Fold() *Rule {
+	r.IgnoreCase = true
+	return r
+}
+
+// WithMatcher sets the fuzzy matcher of the rule and returns it
+func (r *Rule) WithMatcher(m match.Matcher) *Rule {
+	r.Matcher = m
+	return r
+}
+
+func (r *Rule) compile() error {
+	switch r.Mode {
+	case ModeExact, ModePrefix, ModeSuffix, ModeContains, ModeExists:
+	case ModeRegex:
+		r.once.Do(func() {
+			pattern := r.Value
+			if r.IgnoreCase {
+				pattern = "(?i)" + pattern
+			}
+			if r.re, r.err = regexp.Compile(pattern); r.err != nil {
+				r.err = fmt.Errorf("rule %s: %v", r.Target, r.err)
+			}
+		})
+		if r.err != nil {
+			return r.err
+		}
+	case ModeFuzzy:
+	default:
+		return fmt.Errorf("rule %s: unknown mode %q", r.Target, r.Mode)
+	}
+	switch r.Target {
+	case TargetTag, TargetText, TargetAnyAttribute:
+	case TargetAttribute:
+		if r.Attribute == "" {
+			return fmt.Errorf("rule %s: missing attribute name", r.Target)
+		}
+	default:
+		return fmt.Errorf("unknown rule target %q", r.Target)
+	}
+	return nil
+}
+
+func (r *Rule) match(h *HtmlData) bool {
+	switch r.Target {
+	case TargetTag:
+		return r.matchValue(h.Tag)
+	case TargetText:
+		return r.matchValue(h.TextData)
+	case TargetAttribute:
+		v, found := h.Attributes[r.Attribute]
+		return found && r.matchValue(v)
+	case TargetAnyAttribute:
+		for _, v := range h.Attributes {
+			if r.matchValue(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchValue(v string) bool {
+	want := r.Value
+	if r.IgnoreCase && r.Mode != ModeRegex {
+		v, want = strings.ToLower(v), strings.ToLower(want)
+	}
+	switch r.Mode {
+	case ModeExact:
+		return v == want
+	case ModePrefix:
+		return strings.HasPrefix(v, want)
+	case ModeSuffix:
+		return strings.HasSuffix(v, want)
+	case ModeContains:
+		return strings.Contains(v, want)
+	case ModeRegex:
+		return r.re.MatchString(v)
+	case ModeFuzzy:
+		if r.Matcher.Algorithm == "" {
+			return match.Default.Match(want, v)
+		}
+		return r.Matcher.Match(want, v)
+	case ModeExists:
+		return true
+	}
+	return false
+}
+
+type and []Condition
+
+type or []Condition
+
+type not struct {
+	c Condition
+}
+
+// And matches when every condition matches, an empty And matches every element
+func And(conditions ...Condition) Condition {
+	return and(conditions)
+}
+
+// Or matches when any condition matches, an empty Or matches no element
+func Or(conditions ...Condition) Condition {
+	return or(conditions)
+}
+
+// Not matches when the condition does not
+func Not(c Condition) Condition {
+	return not{c: c}
+}
+
+func (a and) compile() error {
+	return compileAll(a)
+}
+
+func (a and) match(h *HtmlData) bool {
+	for _, c := range a {
+		if !c.match(h) {
+			return false
+		}
+	}
+	return true
+}
+
+func (o or) compile() error {
+	return compileAll(o)
+}
+
+func (o or) match(h *HtmlData) bool {
+	for _, c := range o {
+		if c.match(h) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n not) compile() error {
+	if n.c == nil {
+		return fmt.Errorf("not: missing condition")
+	}
+	return n.c.compile()
+}
+
+func (n not) match(h *HtmlData) bool {
+	return !n.c.match(h)
+}
+
+func compileAll(conditions []Condition) error {
+	for _, c := range conditions {
+		if c == nil {
+			return fmt.Errorf("missing condition")
+		}
+		if err := c.compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Query selects elements, results are in document order and every element is returned once
+type Query struct {
+	// Tags limits the elements to these tag names, compared case insensitive, empty allows every tag
+	Tags []string
+	// Where is the condition elements have to match, nil matches every element
+	Where Condition
+	// SkipIDs are elements that are never returned, their children are still searched
+	SkipIDs []string
+	// Limit stops the search once this many elements are found, 0 is no limit
+	Limit int
+}
+
+// Compile checks the query and compiles its patterns, Find calls it so it is only needed to report errors early
+func (q *Query) Compile() error {
+	if q.Where == nil {
+		return nil
+	}
+	return q.Where.compile()
+}
+
+// Find returns the elements of h, h included, matching the query
+func (h *HtmlData) Find(q *Query) ([]*HtmlData, error) {
+	return q.Filter(h.Elements())
+}
+
+// Filter returns the elements of the list matching the query in the order of the list, elements listed twice are returned once
+func (q *Query) Filter(elements []*HtmlData) ([]*HtmlData, error) {
+	if err := q.Compile(); err != nil {
+		return nil, err
+	}
+	var output []*HtmlData
+	seen := map[*HtmlData]struct{}{}
+	for _, e := range elements {
+		if _, found := seen[e]; found {
+			continue
+		}
+		seen[e] = struct{}{}
+		if q.Limit > 0 && len(output) >= q.Limit {
+			break
+		}
+		if len(q.Tags) > 0 && !isInArray(e.Tag, q.Tags) {
+			continue
+		}
+		if isInArray(e.ID, q.SkipIDs) {
+			continue
+		}
+		if q.Where == nil || q.Where.match(e) {
+			output = append(output, e)
+		}
+	}
+	return output, nil
+}
+
+// SearchQuery builds the Query behind Search: every key is OR'd, keys starting with tag, text or * test the tag, the text
+// or any attribute with the value as a regex, as a case insensitive string when it is not a valid regex, and with the matcher,
+// every key is also tried as an attribute name
+func SearchQuery(tags []string, attributes map[string]string, skipId []string, m match.Matcher) *Query {
+	q := &Query{Tags: tags, SkipIDs: skipId}
+	if len(attributes) == 0 {
+		return q
+	}
+	var conditions or
+	for k, v := range attributes {
+		literal := literalRule(v)
+		var targets []Target
+		if strings.HasPrefix(k, "tag") || strings.HasPrefix(k, "*") {
+			targets = append(targets, TargetTag)
+		}
+		if strings.HasPrefix(k, "text") || strings.HasPrefix(k, "*") {
+			targets = append(targets, TargetText)
+		}
+		if strings.HasPrefix(k, "*") {
+			targets = append(targets, TargetAnyAttribute)
+		}
+		for _, t := range targets {
+			conditions = append(conditions, literal(t, ""), &Rule{Target: t, Mode: ModeFuzzy, Value: v, Matcher: m})
+		}
+		conditions = append(conditions, literal(TargetAttribute, k))
+	}
+	q.Where = conditions
+	return q
+}
+
+// literalRule returns a rule builder for value, a regex rule when it compiles and a case insensitive exact rule otherwise
+// the regex is compiled once for every target
+func literalRule(value string) func(target Target, attribute string) *Rule {
+	re, err := regexp.Compile(value)
+	return func(target Target, attribute string) *Rule {
+		if err != nil {
+			return &Rule{Target: target, Attribute: attribute, Mode: ModeExact, Value: value, IgnoreCase: true}
+		}
+		return &Rule{Target: target, Attribute: attribute, Mode: ModeRegex, Value: value, re: re}
+	}
+}
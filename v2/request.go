@@ -2,7 +2,7 @@ package v2
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
 	"io"
 	"io/ioutil"
@@ -15,7 +15,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/patrickmn/go-cache"
 
 	"golang.org/x/net/html"
@@ -26,6 +25,10 @@ type HTMLSourceRequest struct {
 	tokenizer    *html.Tokenizer
 	Cache        *cache.Cache
 	SleepTimeMax int
+	// IDMode sets how node ids are assigned, defaults to RandomIDs
+	IDMode IDMode
+	// Header is sent with every request, ie a Content-Type for POST bodies
+	Header http.Header
 }
 
 // NewHTMLSourceRequest creates a new source request with a http client
@@ -49,32 +52,35 @@ func NewHTMLSourceRequestWithSleep(sleep int) *HTMLSourceRequest {
 
 // GetSourceCode get source code from webpage
 func (r *HTMLSourceRequest) GetSourceCode(searchURL string, method string, body []byte) (*HtmlData, error) {
+	return r.GetSourceCodeContext(context.Background(), searchURL, method, body)
+}
+
+// GetSourceCodeContext is GetSourceCode with a context, cancelling it stops the request and the sleep after it
+func (r *HTMLSourceRequest) GetSourceCodeContext(ctx context.Context, searchURL string, method string, body []byte) (*HtmlData, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if r.Cache == nil {
 		r.Cache = cache.New(5*time.Minute, 10*time.Minute)
 	}
 
-	bytes, found := r.Cache.Get(searchURL)
+	// the cache keeps its own copy and hands out copies, callers are free to change the page they get
+	key := cacheKey(searchURL, method, body)
+	cached, found := r.Cache.Get(key)
 	if found {
-		switch b := bytes.(type) {
+		switch b := cached.(type) {
 		case *HtmlData:
-			d := HtmlData{}
-			data, err := json.Marshal(b)
-			if err != nil {
-				return nil, err
-			}
-			err = json.Unmarshal(data, &d)
-			if err != nil {
-				return nil, err
-			}
-			return &d, nil
+			return b.Clone(), nil
 		}
 	}
 	httpRequestHandler := NewHTMLSourceRequest()
+	httpRequestHandler.IDMode = r.IDMode
+	httpRequestHandler.Header = r.Header
 	u, err := url.Parse(searchURL)
 	if err != nil {
 		return nil, err
 	}
-	err = httpRequestHandler.fullRequest(u, method, body)
+	err = httpRequestHandler.fullRequest(ctx, u, method, body)
 	if err != nil {
 		return nil, err
 	}
@@ -82,55 +88,126 @@ func (r *HTMLSourceRequest) GetSourceCode(searchURL string, method string, body
 	if err != nil {
 		return nil, err
 	}
-	r.Cache.Set(searchURL, pageSource, cache.DefaultExpiration)
-	r.wait()
+	pageSource.AssignIDs(r.IDMode)
+	r.Cache.Set(key, pageSource.Clone(), cache.DefaultExpiration)
+	r.wait(ctx)
 	return pageSource, nil
 }
-func (r *HTMLSourceRequest) wait() {
+
+// cacheKey is the url of GET requests, other requests are cached per method and body
+func cacheKey(searchURL, method string, body []byte) string {
+	if (method == "" || method == http.MethodGet) && len(body) == 0 {
+		return searchURL
+	}
+	return method + " " + searchURL + "\n" + string(body)
+}
+
+func (r *HTMLSourceRequest) wait(ctx context.Context) {
 	if r.SleepTimeMax > 0 {
 		rand.Seed(time.Now().Unix())
 		min := rand.Intn(r.SleepTimeMax)
-		time.Sleep(time.Duration(min) * time.Second)
+		t := time.NewTimer(time.Duration(min) * time.Second)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+		case <-t.C:
+		}
 	}
 }
 func (r *HTMLSourceRequest) ProcessSourceCode(sourceCode string) (*HtmlData, error) {
-	respReader := strings.NewReader(sourceCode)
-	r.tokenizer = html.NewTokenizer(respReader)
+	return r.ProcessReader(strings.NewReader(sourceCode))
+}
+
+// ProcessReader parses html read from a reader, ie a file, a queue message or the export of a headless browser
+func (r *HTMLSourceRequest) ProcessReader(reader io.Reader) (*HtmlData, error) {
+	// the tokenizer stops at any read error as if the page ended, so the error is kept to be returned
+	er := &errReader{r: reader}
+	r.tokenizer = html.NewTokenizer(er)
 	pageSource, err := r.process(0, "", nil)
+	if err == nil {
+		err = er.err
+	}
 	if err != nil {
 		return nil, err
 	}
+	pageSource.AssignIDs(r.IDMode)
 	return pageSource, err
 }
 
+type errReader struct {
+	r   io.Reader
+	err error
+}
+
+func (e *errReader) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	if err != nil && err != io.EOF && e.err == nil {
+		e.err = err
+	}
+	return n, err
+}
+
+// GetRawSource returns the body of a page without parsing it, useful for json endpoints where the html tokenizer
+// would break up markup held inside strings
+func (r *HTMLSourceRequest) GetRawSource(searchURL string, method string, body []byte) ([]byte, error) {
+	return r.GetRawSourceContext(context.Background(), searchURL, method, body)
+}
+
+// GetRawSourceContext is GetRawSource with a context, cancelling it stops the request and the sleep after it
+func (r *HTMLSourceRequest) GetRawSourceContext(ctx context.Context, searchURL string, method string, body []byte) ([]byte, error) {
+	u, err := url.Parse(searchURL)
+	if err != nil {
+		return nil, err
+	}
+	respStr, err := r.fetch(ctx, u, method, body)
+	if err != nil {
+		return nil, err
+	}
+	r.wait(ctx)
+	return respStr, nil
+}
+
 // fullRequest sets up tokenizer
-func (r *HTMLSourceRequest) fullRequest(url *url.URL, method string, body []byte) error {
-	req, err := http.NewRequest(method, url.String(), bytes.NewBuffer(body))
+func (r *HTMLSourceRequest) fullRequest(ctx context.Context, url *url.URL, method string, body []byte) error {
+	respStr, err := r.fetch(ctx, url, method, body)
 	if err != nil {
 		return err
 	}
-	resp, err := r.client.Do(req)
+	respReader := strings.NewReader(string(respStr))
+	r.tokenizer = html.NewTokenizer(respReader)
+	r.wait(ctx)
+	return nil
+}
+
+func (r *HTMLSourceRequest) fetch(ctx context.Context, url *url.URL, method string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url.String(), bytes.NewBuffer(body))
 	if err != nil {
-		return err
+		return nil, err
+	}
+	for k, values := range r.Header {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	client := r.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
 	}
 	if resp.StatusCode != http.StatusOK {
 		defer func() { _ = resp.Body.Close() }()
 		respStr, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		println(string(respStr))
-		return errors.New("bad status code")
+		return nil, errors.New("bad status code")
 	}
 	defer func() { _ = resp.Body.Close() }()
-	respStr, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
-	respReader := strings.NewReader(string(respStr))
-	r.tokenizer = html.NewTokenizer(respReader)
-	r.wait()
-	return nil
+	return ioutil.ReadAll(resp.Body)
 }
 
 // Download will download a file given a url to a given path
@@ -198,13 +275,12 @@ func (r *HTMLSourceRequest) Download(u, path string) (string, error) {
 		//p.Logger.Error("failed saving image", zap.Error(err))
 		return "", nil
 	}
-	r.wait()
+	r.wait(context.Background())
 	return path, nil
 }
 
 func (r *HTMLSourceRequest) process(depth int, currentTag string, parent *HtmlData) (*HtmlData, error) {
 	RootHtmlData := &HtmlData{
-		ID:         uuid.New().String(),
 		Parent:     parent,
 		Tag:        currentTag,
 		Attributes: map[string]string{},
@@ -223,16 +299,12 @@ func (r *HTMLSourceRequest) process(depth int, currentTag string, parent *HtmlDa
 				return RootHtmlData, nil
 			}
 		case html.SelfClosingTagToken:
-			t := &HtmlData{
-				Parent:     parent,
-				Tag:        token.Data,
-				Attributes: map[string]string{},
-			}
-			for _, v := range token.Attr {
-				t.Attributes[v.Key] = v.Val
-			}
-			RootHtmlData.Sibling = append(RootHtmlData.Sibling, t)
+			RootHtmlData.addSelfClosing(token)
 		case html.StartTagToken:
+			if _, found := voidElements[token.Data]; found {
+				RootHtmlData.addSelfClosing(token)
+				continue
+			}
 			depth += 1
 			child, err := r.process(depth, token.Data, RootHtmlData)
 			for _, v := range token.Attr {
@@ -242,10 +314,35 @@ func (r *HTMLSourceRequest) process(depth int, currentTag string, parent *HtmlDa
 				return RootHtmlData, nil
 			}
 			RootHtmlData.Child = append(RootHtmlData.Child, child)
+			RootHtmlData.content = append(RootHtmlData.content, contentPart{node: child})
 		case html.EndTagToken:
 			return RootHtmlData, nil
 		case html.TextToken:
 			RootHtmlData.TextData = strings.TrimSpace(RootHtmlData.TextData + token.Data)
+			RootHtmlData.content = append(RootHtmlData.content, contentPart{text: token.Data})
 		}
 	}
 }
+
+// voidElements never have an end tag, without this they would take the rest of the page as children
+// so an <img> written without a slash is a sibling in the tree like <img/> is
+var voidElements = map[string]struct{}{
+	"area": {}, "base": {}, "br": {}, "col": {}, "embed": {}, "hr": {}, "img": {}, "input": {},
+	"link": {}, "meta": {}, "param": {}, "source": {}, "track": {}, "wbr": {},
+}
+
+// addSelfClosing adds an element that has no content of its own as a sibling of the current node
+// its Parent is the node holding it, not the node above that, so Search results of such elements
+// (img, br, input, ...) can be walked up from like any other element
+func (h *HtmlData) addSelfClosing(token html.Token) {
+	t := &HtmlData{
+		Parent:     h,
+		Tag:        token.Data,
+		Attributes: map[string]string{},
+	}
+	for _, v := range token.Attr {
+		t.Attributes[v.Key] = v.Val
+	}
+	h.Sibling = append(h.Sibling, t)
+	h.content = append(h.content, contentPart{node: t})
+}
@@ -0,0 +1,462 @@
+package v2
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ScriptData is a JSON or javascript literal found inside a <script>
+type ScriptData struct {
+	// Name is the variable the literal is assigned to without window., ie __INITIAL_STATE__ or images,
+	// the id of the script for scripts holding only data like __NEXT_DATA__, or JSON.parse
+	Name string `json:"name"`
+	// Value is a map[string]interface{}, []interface{}, string, float64, bool or nil
+	Value interface{} `json:"value"`
+	Node  *HtmlData   `json:"-"`
+}
+
+var (
+	scriptAssignment = regexp.MustCompile(`(?:\b(?:var|let|const)\s+|\b(?:window|self|globalThis|this)\s*\.\s*)?` +
+		`([A-Za-z_$][\w$]*(?:\s*\.\s*[A-Za-z_$][\w$]*|\[\s*['"][^'"]+['"]\s*\])*)\s*=\s*(?:JSON\.parse\(\s*)?(['"{\[])`)
+	scriptJSONParse = regexp.MustCompile(`JSON\.parse\(\s*(['"])`)
+)
+
+// ScriptData returns the literals held by the scripts of the page in document order
+// scripts holding only data (application/json, ld+json, __NEXT_DATA__) give a single value named after their id or type,
+// other scripts give every object or array assigned to a variable or passed to JSON.parse
+func (h *HtmlData) ScriptData() []*ScriptData {
+	var output []*ScriptData
+	for _, s := range h.GetTags([]string{"script"}) {
+		output = append(output, scriptData(s)...)
+	}
+	return output
+}
+
+// QueryScripts runs a JSONPath expression against every literal of the page's scripts and returns all the matches
+func (h *HtmlData) QueryScripts(path string) ([]interface{}, error) {
+	var output []interface{}
+	for _, d := range h.ScriptData() {
+		found, err := d.Query(path)
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, found...)
+	}
+	return output, nil
+}
+
+// Query runs a JSONPath expression against the value, see JSONPath
+func (d *ScriptData) Query(path string) ([]interface{}, error) {
+	return JSONPath(d.Value, path)
+}
+
+func scriptData(s *HtmlData) []*ScriptData {
+	text := strings.TrimSpace(s.TextData)
+	if text == "" {
+		return nil
+	}
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "<!--"), "-->")
+	if v, end, err := parseLiteral(text, 0); err == nil && isContainer(v) && strings.TrimSpace(strings.TrimRight(text[end:], ";")) == "" {
+		name := firstNonEmpty(s.Attributes["id"], s.Attributes["type"], "script")
+		return []*ScriptData{{Name: name, Value: v, Node: s}}
+	}
+	type found struct {
+		start, end int
+		data       *ScriptData
+	}
+	var literals []found
+	inside := func(offset int) bool {
+		for _, l := range literals {
+			if offset >= l.start && offset < l.end {
+				return true
+			}
+		}
+		return false
+	}
+	for _, m := range scriptAssignment.FindAllStringSubmatchIndex(text, -1) {
+		if inside(m[0]) {
+			continue
+		}
+		v, end, err := parseLiteral(text, m[4])
+		if err != nil {
+			continue
+		}
+		if str, ok := v.(string); ok {
+			// only strings given to JSON.parse are data, other strings are plain assignments
+			if !strings.Contains(text[m[0]:m[4]], "JSON.parse") {
+				continue
+			}
+			if v, _, err = parseLiteral(str, 0); err != nil {
+				continue
+			}
+		}
+		name := strings.Join(strings.Fields(text[m[2]:m[3]]), "")
+		literals = append(literals, found{m[0], end, &ScriptData{Name: name, Value: v, Node: s}})
+	}
+	for _, m := range scriptJSONParse.FindAllStringSubmatchIndex(text, -1) {
+		if inside(m[0]) {
+			continue
+		}
+		str, end, err := parseLiteral(text, m[2])
+		if err != nil {
+			continue
+		}
+		if v, _, err := parseLiteral(str.(string), 0); err == nil {
+			literals = append(literals, found{m[0], end, &ScriptData{Name: "JSON.parse", Value: v, Node: s}})
+		}
+	}
+	sort.Slice(literals, func(i, j int) bool {
+		return literals[i].start < literals[j].start
+	})
+	output := make([]*ScriptData, 0, len(literals))
+	for _, l := range literals {
+		output = append(output, l.data)
+	}
+	return output
+}
+
+func isContainer(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	}
+	return false
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// ParseLiteral parses a JSON value or a javascript object, array or primitive literal
+// single and backtick quoted strings, unquoted keys, trailing commas, comments and escaped slashes are accepted,
+// undefined, NaN, Infinity and references to other variables become nil
+func ParseLiteral(s string) (interface{}, error) {
+	v, end, err := parseLiteral(s, 0)
+	if err != nil {
+		return nil, err
+	}
+	if rest := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(s[end:]), ";")); rest != "" {
+		return nil, fmt.Errorf("unexpected %q at offset %d", rest[:1], end)
+	}
+	return v, nil
+}
+
+func parseLiteral(s string, start int) (interface{}, int, error) {
+	p := &literalParser{s: s, pos: start}
+	v, err := p.value()
+	return v, p.pos, err
+}
+
+type literalParser struct {
+	s   string
+	pos int
+}
+
+func (p *literalParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// skip moves past whitespace and comments
+func (p *literalParser) skip() {
+	for p.pos < len(p.s) {
+		switch {
+		case strings.HasPrefix(p.s[p.pos:], "//"):
+			if i := strings.IndexByte(p.s[p.pos:], '\n'); i >= 0 {
+				p.pos += i + 1
+			} else {
+				p.pos = len(p.s)
+			}
+		case strings.HasPrefix(p.s[p.pos:], "/*"):
+			if i := strings.Index(p.s[p.pos+2:], "*/"); i >= 0 {
+				p.pos += i + 4
+			} else {
+				p.pos = len(p.s)
+			}
+		default:
+			r, size := utf8.DecodeRuneInString(p.s[p.pos:])
+			if !unicode.IsSpace(r) {
+				return
+			}
+			p.pos += size
+		}
+	}
+}
+
+func (p *literalParser) value() (interface{}, error) {
+	p.skip()
+	if p.pos >= len(p.s) {
+		return nil, p.errorf("unexpected end of input")
+	}
+	switch c := p.s[p.pos]; {
+	case c == '{':
+		return p.object()
+	case c == '[':
+		return p.array()
+	case c == '"' || c == '\'' || c == '`':
+		return p.str()
+	case c == '-' || c == '+' || c == '.' || c >= '0' && c <= '9':
+		return p.number()
+	case isIdentStart(rune(c)):
+		ident := p.identifier()
+		switch ident {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null", "undefined", "NaN", "Infinity":
+			return nil, nil
+		case "new", "function":
+			return nil, p.errorf("%s expressions are not literals", ident)
+		}
+		// a reference like other.value, its value is not known
+		for p.pos < len(p.s) && p.s[p.pos] == '.' {
+			p.pos++
+			p.identifier()
+		}
+		p.skip()
+		if p.pos < len(p.s) && (p.s[p.pos] == '(' || p.s[p.pos] == '[') {
+			return nil, p.errorf("%s is an expression, not a literal", ident)
+		}
+		return nil, nil
+	}
+	return nil, p.errorf("unexpected %q", p.s[p.pos])
+}
+
+func (p *literalParser) object() (interface{}, error) {
+	p.pos++
+	obj := map[string]interface{}{}
+	for {
+		p.skip()
+		if p.pos >= len(p.s) {
+			return nil, p.errorf("unterminated object")
+		}
+		if p.s[p.pos] == '}' {
+			p.pos++
+			return obj, nil
+		}
+		var key string
+		switch c := p.s[p.pos]; {
+		case c == '"' || c == '\'' || c == '`':
+			k, err := p.str()
+			if err != nil {
+				return nil, err
+			}
+			key = k.(string)
+		case c >= '0' && c <= '9':
+			n, err := p.number()
+			if err != nil {
+				return nil, err
+			}
+			key = strconv.FormatFloat(n.(float64), 'f', -1, 64)
+		case isIdentStart(rune(c)):
+			key = p.identifier()
+		default:
+			return nil, p.errorf("unexpected %q in object key", c)
+		}
+		p.skip()
+		if p.pos >= len(p.s) || p.s[p.pos] != ':' {
+			return nil, p.errorf("expected : after key %q", key)
+		}
+		p.pos++
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		obj[key] = v
+		p.skip()
+		if p.pos < len(p.s) && p.s[p.pos] == ',' {
+			p.pos++
+			continue
+		}
+		if p.pos < len(p.s) && p.s[p.pos] == '}' {
+			p.pos++
+			return obj, nil
+		}
+		return nil, p.errorf("expected , or } in object")
+	}
+}
+
+func (p *literalParser) array() (interface{}, error) {
+	p.pos++
+	arr := []interface{}{}
+	for {
+		p.skip()
+		if p.pos >= len(p.s) {
+			return nil, p.errorf("unterminated array")
+		}
+		if p.s[p.pos] == ']' {
+			p.pos++
+			return arr, nil
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+		p.skip()
+		if p.pos < len(p.s) && p.s[p.pos] == ',' {
+			p.pos++
+			continue
+		}
+		if p.pos < len(p.s) && p.s[p.pos] == ']' {
+			p.pos++
+			return arr, nil
+		}
+		return nil, p.errorf("expected , or ] in array")
+	}
+}
+
+func (p *literalParser) str() (interface{}, error) {
+	quote := p.s[p.pos]
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return b.String(), nil
+		case c == '\\' && p.pos+1 < len(p.s):
+			p.pos++
+			p.escape(&b)
+		case c == '$' && quote == '`' && p.pos+1 < len(p.s) && p.s[p.pos+1] == '{':
+			return nil, p.errorf("template literals with expressions are not literals")
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+	return nil, p.errorf("unterminated string")
+}
+
+// escape writes the character of the escape sequence at the current position, unknown escapes like \/ give the character itself
+func (p *literalParser) escape(b *strings.Builder) {
+	c := p.s[p.pos]
+	p.pos++
+	switch c {
+	case 'n':
+		b.WriteByte('\n')
+	case 't':
+		b.WriteByte('\t')
+	case 'r':
+		b.WriteByte('\r')
+	case 'b':
+		b.WriteByte('\b')
+	case 'f':
+		b.WriteByte('\f')
+	case 'v':
+		b.WriteByte('\v')
+	case '0':
+		b.WriteByte(0)
+	case '\n':
+		// a line continuation
+	case 'x':
+		if r, ok := p.hex(2); ok {
+			b.WriteRune(r)
+		}
+	case 'u':
+		if p.pos < len(p.s) && p.s[p.pos] == '{' {
+			end := strings.IndexByte(p.s[p.pos:], '}')
+			if end > 0 {
+				if n, err := strconv.ParseUint(p.s[p.pos+1:p.pos+end], 16, 32); err == nil {
+					b.WriteRune(rune(n))
+					p.pos += end + 1
+					return
+				}
+			}
+		}
+		r, ok := p.hex(4)
+		if !ok {
+			return
+		}
+		// surrogate pairs are written as two escapes in JSON
+		if r >= 0xD800 && r < 0xDC00 && strings.HasPrefix(p.s[p.pos:], `\u`) {
+			p.pos += 2
+			if low, ok := p.hex(4); ok {
+				r = (r-0xD800)<<10 + (low - 0xDC00) + 0x10000
+			}
+		}
+		b.WriteRune(r)
+	default:
+		b.WriteByte(c)
+	}
+}
+
+func (p *literalParser) hex(digits int) (rune, bool) {
+	if p.pos+digits > len(p.s) {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(p.s[p.pos:p.pos+digits], 16, 32)
+	if err != nil {
+		return 0, false
+	}
+	p.pos += digits
+	return rune(n), true
+}
+
+func (p *literalParser) number() (interface{}, error) {
+	start := p.pos
+	if p.s[p.pos] == '-' || p.s[p.pos] == '+' {
+		p.pos++
+	}
+	if strings.HasPrefix(p.s[p.pos:], "Infinity") {
+		p.pos += len("Infinity")
+		return nil, nil
+	}
+	if strings.HasPrefix(strings.ToLower(p.s[p.pos:]), "0x") {
+		p.pos += 2
+		hexStart := p.pos
+		for p.pos < len(p.s) && strings.IndexByte("0123456789abcdefABCDEF", p.s[p.pos]) >= 0 {
+			p.pos++
+		}
+		n, err := strconv.ParseInt(p.s[hexStart:p.pos], 16, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", p.s[start:p.pos])
+		}
+		if p.s[start] == '-' {
+			n = -n
+		}
+		return float64(n), nil
+	}
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if c >= '0' && c <= '9' || c == '.' || c == 'e' || c == 'E' || c == '_' ||
+			(c == '-' || c == '+') && (p.s[p.pos-1] == 'e' || p.s[p.pos-1] == 'E') {
+			p.pos++
+			continue
+		}
+		break
+	}
+	n, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimPrefix(p.s[start:p.pos], "+"), "_", ""), 64)
+	if err != nil || math.IsInf(n, 0) {
+		return nil, p.errorf("invalid number %q", p.s[start:p.pos])
+	}
+	return n, nil
+}
+
+func (p *literalParser) identifier() string {
+	start := p.pos
+	for p.pos < len(p.s) {
+		r, size := utf8.DecodeRuneInString(p.s[p.pos:])
+		if !isIdentStart(r) && !unicode.IsDigit(r) {
+			break
+		}
+		p.pos += size
+	}
+	return p.s[start:p.pos]
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r)
+}
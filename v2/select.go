@@ -0,0 +1,502 @@
+package v2
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Selector is a compiled css selector, see CompileSelector
+type Selector struct {
+	source string
+	groups [][]*compound
+}
+
+// compound is a run of simple selectors like a.title[href], combinator links it to the compound before it
+type compound struct {
+	// scope stands for the element Select is called on
+	scope      bool
+	combinator byte
+	tag        string
+	id         string
+	classes    []string
+	attributes []attributeSelector
+	pseudo     []pseudoSelector
+}
+
+type attributeSelector struct {
+	name  string
+	op    string
+	value string
+}
+
+type pseudoSelector struct {
+	name string
+	n    int
+	not  *Selector
+}
+
+// CompileSelector compiles a css selector, supported are tag, *, #id, .class, [attr], [attr=v], [attr~=v], [attr|=v],
+// [attr^=v], [attr$=v], [attr*=v] with an optional i flag, the descendant ( ), child (>), adjacent (+) and sibling (~)
+// combinators, groups separated by commas and :first-child, :last-child, :only-child, :nth-child(n), :nth-last-child(n),
+// :empty and :not(selector)
+func CompileSelector(selector string) (*Selector, error) {
+	s := &Selector{source: selector}
+	for _, group := range splitGroups(selector) {
+		compounds, err := parseCompounds(strings.TrimSpace(group))
+		if err != nil {
+			return nil, fmt.Errorf("selector %q: %v", selector, err)
+		}
+		s.groups = append(s.groups, compounds)
+	}
+	return s, nil
+}
+
+// MustCompileSelector is CompileSelector for selectors known to be valid, it panics on errors
+func MustCompileSelector(selector string) *Selector {
+	s, err := CompileSelector(selector)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func (s *Selector) String() string {
+	return s.source
+}
+
+// splitGroups splits a selector on the commas that are not inside quotes, brackets or parentheses
+func splitGroups(selector string) []string {
+	var groups []string
+	var quote byte
+	depth, start := 0, 0
+	for i := 0; i < len(selector); i++ {
+		c := selector[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			depth--
+		case c == ',' && depth == 0:
+			groups = append(groups, selector[start:i])
+			start = i + 1
+		}
+	}
+	return append(groups, selector[start:])
+}
+
+func parseCompounds(group string) ([]*compound, error) {
+	if group == "" {
+		return nil, fmt.Errorf("empty selector")
+	}
+	var compounds []*compound
+	combinator := byte(0)
+	for i := 0; i < len(group); {
+		c := group[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if combinator == 0 && len(compounds) > 0 {
+				combinator = ' '
+			}
+			i++
+			continue
+		case c == '>' || c == '+' || c == '~':
+			if len(compounds) == 0 {
+				// a leading combinator is relative to the element Select is called on, ie "> li"
+				compounds = append(compounds, &compound{scope: true})
+			}
+			combinator = c
+			i++
+			continue
+		}
+		cp, end, err := parseCompound(group, i)
+		if err != nil {
+			return nil, err
+		}
+		cp.combinator = combinator
+		compounds = append(compounds, cp)
+		combinator = 0
+		i = end
+	}
+	if combinator != 0 && combinator != ' ' {
+		return nil, fmt.Errorf("%q has nothing after it", combinator)
+	}
+	return compounds, nil
+}
+
+func isNameByte(c byte) bool {
+	return c == '-' || c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func readName(s string, i int) (string, int) {
+	end := i
+	for end < len(s) && (isNameByte(s[end]) || s[end] == '\\' && end+1 < len(s)) {
+		if s[end] == '\\' {
+			end++
+		}
+		end++
+	}
+	return strings.ReplaceAll(s[i:end], `\`, ""), end
+}
+
+func parseCompound(s string, i int) (*compound, int, error) {
+	cp := &compound{}
+	start := i
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == '*':
+			cp.tag = "*"
+			i++
+		case isNameByte(c) && i == start:
+			cp.tag, i = readName(s, i)
+			cp.tag = strings.ToLower(cp.tag)
+		case c == '#':
+			cp.id, i = readName(s, i+1)
+			if cp.id == "" {
+				return nil, 0, fmt.Errorf("empty id at %d", i)
+			}
+		case c == '.':
+			var class string
+			class, i = readName(s, i+1)
+			if class == "" {
+				return nil, 0, fmt.Errorf("empty class at %d", i)
+			}
+			cp.classes = append(cp.classes, class)
+		case c == '[':
+			end := closingBracket(s, i)
+			if end < 0 {
+				return nil, 0, fmt.Errorf("unterminated [ at %d", i)
+			}
+			a, err := parseAttributeSelector(s[i+1 : end])
+			if err != nil {
+				return nil, 0, err
+			}
+			cp.attributes = append(cp.attributes, a)
+			i = end + 1
+		case c == ':':
+			p, end, err := parsePseudo(s, i+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			cp.pseudo = append(cp.pseudo, p)
+			i = end
+		default:
+			if i == start {
+				return nil, 0, fmt.Errorf("unexpected %q at %d", c, i)
+			}
+			return cp, i, nil
+		}
+	}
+	return cp, i, nil
+}
+
+func parseAttributeSelector(inner string) (attributeSelector, error) {
+	inner = strings.TrimSpace(inner)
+	a := attributeSelector{}
+	name, end := readName(inner, 0)
+	if name == "" {
+		return a, fmt.Errorf("empty attribute in [%s]", inner)
+	}
+	a.name = strings.ToLower(name)
+	rest := strings.TrimSpace(inner[end:])
+	if rest == "" {
+		return a, nil
+	}
+	for _, op := range []string{"~=", "|=", "^=", "$=", "*=", "="} {
+		if strings.HasPrefix(rest, op) {
+			a.op = op
+			rest = strings.TrimSpace(rest[len(op):])
+			break
+		}
+	}
+	if a.op == "" {
+		return a, fmt.Errorf("unknown operator in [%s]", inner)
+	}
+	ignoreCase := false
+	if strings.HasSuffix(rest, " i") || strings.HasSuffix(rest, " I") {
+		ignoreCase = true
+		rest = strings.TrimSpace(rest[:len(rest)-2])
+	}
+	if strings.HasPrefix(rest, "'") || strings.HasPrefix(rest, `"`) {
+		v, err := ParseLiteral(rest)
+		value, ok := v.(string)
+		if err != nil || !ok {
+			return a, fmt.Errorf("invalid value in [%s]", inner)
+		}
+		rest = value
+	}
+	a.value = rest
+	if ignoreCase {
+		a.op = "i" + a.op
+		a.value = strings.ToLower(a.value)
+	}
+	return a, nil
+}
+
+func parsePseudo(s string, i int) (pseudoSelector, int, error) {
+	p := pseudoSelector{}
+	p.name, i = readName(s, i)
+	p.name = strings.ToLower(p.name)
+	arg := ""
+	if i < len(s) && s[i] == '(' {
+		depth, end := 0, -1
+		for j := i; j < len(s); j++ {
+			if s[j] == '(' {
+				depth++
+			} else if s[j] == ')' {
+				depth--
+				if depth == 0 {
+					end = j
+					break
+				}
+			}
+		}
+		if end < 0 {
+			return p, 0, fmt.Errorf("unterminated ( in :%s", p.name)
+		}
+		arg = strings.TrimSpace(s[i+1 : end])
+		i = end + 1
+	}
+	switch p.name {
+	case "first-child", "last-child", "only-child", "empty":
+	case "nth-child", "nth-last-child":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			return p, 0, fmt.Errorf(":%s needs a position starting at 1, got %q", p.name, arg)
+		}
+		p.n = n
+	case "not":
+		not, err := CompileSelector(arg)
+		if err != nil {
+			return p, 0, err
+		}
+		p.not = not
+	default:
+		return p, 0, fmt.Errorf("unsupported pseudo class :%s", p.name)
+	}
+	return p, i, nil
+}
+
+// Select returns the elements inside h matching the css selector in document order, see CompileSelector
+// like querySelectorAll h itself is never returned, unlike it ancestors are only matched inside h so selectors are relative to h
+func (h *HtmlData) Select(selector string) ([]*HtmlData, error) {
+	s, err := CompileSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return h.SelectCompiled(s), nil
+}
+
+// SelectFirst returns the first element matching the selector or nil
+func (h *HtmlData) SelectFirst(selector string) (*HtmlData, error) {
+	found, err := h.Select(selector)
+	if err != nil || len(found) == 0 {
+		return nil, err
+	}
+	return found[0], nil
+}
+
+// SelectCompiled is Select with a selector compiled once
+func (h *HtmlData) SelectCompiled(s *Selector) []*HtmlData {
+	var output []*HtmlData
+	for _, e := range h.Elements()[1:] {
+		if s.matches(e, h) {
+			output = append(output, e)
+		}
+	}
+	return output
+}
+
+// Matches reports whether the element matches the selector, ancestors are looked for up to the root of the page
+func (s *Selector) Matches(h *HtmlData) bool {
+	return s.matches(h, nil)
+}
+
+func (s *Selector) matches(h, scope *HtmlData) bool {
+	for _, g := range s.groups {
+		if matchCompounds(h, g, len(g)-1, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchCompounds matches the compounds up to last from right to left, scope is the element ancestors can not go past
+func matchCompounds(h *HtmlData, compounds []*compound, last int, scope *HtmlData) bool {
+	cp := compounds[last]
+	if cp.scope {
+		return h == scope
+	}
+	if h == scope || !cp.match(h) {
+		return false
+	}
+	if last == 0 {
+		return true
+	}
+	switch cp.combinator {
+	case '>':
+		return h.Parent != nil && matchCompounds(h.Parent, compounds, last-1, scope)
+	case '+':
+		prev := previousElements(h)
+		return len(prev) > 0 && matchCompounds(prev[len(prev)-1], compounds, last-1, scope)
+	case '~':
+		for _, p := range previousElements(h) {
+			if matchCompounds(p, compounds, last-1, scope) {
+				return true
+			}
+		}
+		return false
+	default:
+		for p := h.Parent; p != nil; p = p.Parent {
+			if matchCompounds(p, compounds, last-1, scope) {
+				return true
+			}
+			if p == scope {
+				break
+			}
+		}
+		return false
+	}
+}
+
+func (cp *compound) match(h *HtmlData) bool {
+	if h.Tag == "" {
+		// the document root holds the page but is not an element
+		return false
+	}
+	if cp.tag != "" && cp.tag != "*" && !strings.EqualFold(cp.tag, h.Tag) {
+		return false
+	}
+	if cp.id != "" && h.Attributes["id"] != cp.id {
+		return false
+	}
+	if len(cp.classes) > 0 {
+		classes := strings.Fields(h.Attributes["class"])
+		for _, c := range cp.classes {
+			if !isInList(c, classes) {
+				return false
+			}
+		}
+	}
+	for _, a := range cp.attributes {
+		if !a.match(h) {
+			return false
+		}
+	}
+	for _, p := range cp.pseudo {
+		if !p.match(h) {
+			return false
+		}
+	}
+	return true
+}
+
+func isInList(v string, list []string) bool {
+	for _, l := range list {
+		if l == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (a attributeSelector) match(h *HtmlData) bool {
+	v, found := h.Attributes[a.name]
+	if !found {
+		return false
+	}
+	op := a.op
+	if strings.HasPrefix(op, "i") {
+		v, op = strings.ToLower(v), op[1:]
+	}
+	switch op {
+	case "":
+		return true
+	case "=":
+		return v == a.value
+	case "~=":
+		return isInList(a.value, strings.Fields(v))
+	case "|=":
+		return v == a.value || strings.HasPrefix(v, a.value+"-")
+	case "^=":
+		return a.value != "" && strings.HasPrefix(v, a.value)
+	case "$=":
+		return a.value != "" && strings.HasSuffix(v, a.value)
+	case "*=":
+		return a.value != "" && strings.Contains(v, a.value)
+	}
+	return false
+}
+
+func (p pseudoSelector) match(h *HtmlData) bool {
+	switch p.name {
+	case "empty":
+		for _, part := range h.parts() {
+			if part.node != nil || strings.TrimSpace(part.text) != "" {
+				return false
+			}
+		}
+		return true
+	case "not":
+		return !p.not.matches(h, nil)
+	}
+	siblings := childElements(h.Parent)
+	index := -1
+	for i, s := range siblings {
+		if s == h {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return false
+	}
+	switch p.name {
+	case "first-child":
+		return index == 0
+	case "last-child":
+		return index == len(siblings)-1
+	case "only-child":
+		return len(siblings) == 1
+	case "nth-child":
+		return index == p.n-1
+	case "nth-last-child":
+		return len(siblings)-index == p.n
+	}
+	return false
+}
+
+// childElements returns the elements directly inside h in document order
+func childElements(h *HtmlData) []*HtmlData {
+	if h == nil {
+		return nil
+	}
+	var output []*HtmlData
+	for _, part := range h.parts() {
+		if part.node != nil {
+			output = append(output, part.node)
+		}
+	}
+	return output
+}
+
+// previousElements returns the elements before h inside its parent in document order
+func previousElements(h *HtmlData) []*HtmlData {
+	var output []*HtmlData
+	for _, s := range childElements(h.Parent) {
+		if s == h {
+			return output
+		}
+		output = append(output, s)
+	}
+	return nil
+}
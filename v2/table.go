@@ -0,0 +1,306 @@
+package v2
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Table is a <table> laid out as a grid, rowspan and colspan are expanded so every row has a cell for every column
+type Table struct {
+	Node    *HtmlData `json:"-"`
+	Caption string    `json:"caption"`
+	// Headers are the column names found from the thead, a first row of th cells or the first row of the table
+	Headers []string       `json:"headers"`
+	Rows    [][]*TableCell `json:"rows"`
+}
+
+// TableCell is a td or th in a Table
+type TableCell struct {
+	Node    *HtmlData `json:"-"`
+	Text    string    `json:"text"`
+	Links   []string  `json:"links"`
+	Header  bool      `json:"header"`
+	RowSpan int       `json:"row_span"`
+	ColSpan int       `json:"col_span"`
+	// Spanned is true when the cell is a copy filling the space of an earlier cell's rowspan or colspan
+	Spanned bool `json:"spanned"`
+}
+
+// Tables will return every table in the element as a grid of cells with detected headers
+// links in the cells are built using baseLink the same way FindLinks builds them
+func (h *HtmlData) Tables(baseLink string) []*Table {
+	return tableFrom(h, baseLink, func(c *HtmlData) string {
+		return c.Text()
+	})
+}
+
+// Records returns a map for every row keyed by the table headers
+// duplicate headers get a number added to them and missing headers are named column_<index>
+func (t *Table) Records() []map[string]string {
+	keys := t.keys()
+	output := make([]map[string]string, 0, len(t.Rows))
+	for _, row := range t.Rows {
+		record := map[string]string{}
+		for i, c := range row {
+			record[keys[i]] = c.Text
+		}
+		output = append(output, record)
+	}
+	return output
+}
+
+// CSV returns the table as csv with the headers as the first line
+func (t *Table) CSV() (string, error) {
+	b := strings.Builder{}
+	w := csv.NewWriter(&b)
+	if len(t.Headers) > 0 {
+		if err := w.Write(t.Headers); err != nil {
+			return "", err
+		}
+	}
+	for _, row := range t.Rows {
+		if err := w.Write(cellText(row)); err != nil {
+			return "", err
+		}
+	}
+	w.Flush()
+	return b.String(), w.Error()
+}
+
+func (t *Table) keys() []string {
+	columns := len(t.Headers)
+	for _, r := range t.Rows {
+		if len(r) > columns {
+			columns = len(r)
+		}
+	}
+	keys := make([]string, columns)
+	used := map[string]int{}
+	for i := range keys {
+		key := ""
+		if i < len(t.Headers) {
+			key = t.Headers[i]
+		}
+		if key == "" {
+			key = "column_" + strconv.Itoa(i)
+		}
+		used[key]++
+		if used[key] > 1 {
+			key = fmt.Sprintf("%s_%d", key, used[key])
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+func cellText(row []*TableCell) []string {
+	out := make([]string, len(row))
+	for i, c := range row {
+		out[i] = c.Text
+	}
+	return out
+}
+
+// tableFrom finds the tables in h and builds their grids using text to get the content of each cell
+func tableFrom(h *HtmlData, baseLink string, text func(c *HtmlData) string) []*Table {
+	var output []*Table
+	for _, node := range h.GetTags([]string{"table"}) {
+		t := &Table{Node: node}
+		var headRows, bodyRows []*HtmlData
+		for _, r := range tableRows(node, false) {
+			if r.head {
+				headRows = append(headRows, r.node)
+			} else {
+				bodyRows = append(bodyRows, r.node)
+			}
+		}
+		for _, c := range node.GetTags([]string{"caption"}) {
+			t.Caption = c.Text()
+			break
+		}
+		grid := buildGrid(append(headRows, bodyRows...), baseLink, text)
+		switch {
+		case len(headRows) > 0:
+			t.Headers = headerNames(grid[:len(headRows)])
+			t.Rows = grid[len(headRows):]
+		case len(grid) > 1:
+			// a first row of th cells is the usual header, without one the first row is still the best guess
+			t.Headers = cellText(grid[0])
+			t.Rows = grid[1:]
+		default:
+			t.Rows = grid
+		}
+		output = append(output, t)
+	}
+	return output
+}
+
+type tableRow struct {
+	node *HtmlData
+	head bool
+}
+
+// tableRows returns the rows of a table in order without the rows of any table nested inside it
+func tableRows(h *HtmlData, head bool) []tableRow {
+	var rows []tableRow
+	for _, p := range h.parts() {
+		if p.node == nil {
+			continue
+		}
+		switch strings.ToLower(p.node.Tag) {
+		case "table":
+			continue
+		case "tr":
+			rows = append(rows, tableRow{node: p.node, head: head})
+		case "thead":
+			rows = append(rows, tableRows(p.node, true)...)
+		default:
+			rows = append(rows, tableRows(p.node, head)...)
+		}
+	}
+	return rows
+}
+
+// tableCells returns the td and th elements of a row
+func tableCells(row *HtmlData) []*HtmlData {
+	var cells []*HtmlData
+	for _, p := range row.parts() {
+		if p.node == nil {
+			continue
+		}
+		switch strings.ToLower(p.node.Tag) {
+		case "td", "th":
+			cells = append(cells, p.node)
+		case "table", "tr":
+		default:
+			cells = append(cells, tableCells(p.node)...)
+		}
+	}
+	return cells
+}
+
+func buildGrid(rows []*HtmlData, baseLink string, text func(c *HtmlData) string) [][]*TableCell {
+	type span struct {
+		cell      *TableCell
+		remaining int
+	}
+	pending := map[int]*span{}
+	grid := make([][]*TableCell, 0, len(rows))
+	columns := 0
+	for _, r := range rows {
+		var row []*TableCell
+		fillSpans := func() {
+			for {
+				s, found := pending[len(row)]
+				if !found || s.remaining == 0 {
+					return
+				}
+				row = append(row, s.cell.spanCopy())
+				s.remaining--
+			}
+		}
+		for _, node := range tableCells(r) {
+			fillSpans()
+			cell := &TableCell{
+				Node:    node,
+				Text:    text(node),
+				Links:   cellLinks(node, baseLink),
+				Header:  strings.EqualFold(node.Tag, "th"),
+				RowSpan: spanAttribute(node, "rowspan"),
+				ColSpan: spanAttribute(node, "colspan"),
+			}
+			for i := 0; i < cell.ColSpan; i++ {
+				c := cell
+				if i > 0 {
+					c = cell.spanCopy()
+				}
+				if cell.RowSpan > 1 {
+					pending[len(row)] = &span{cell: c, remaining: cell.RowSpan - 1}
+				}
+				row = append(row, c)
+			}
+		}
+		fillSpans()
+		// a rowspan past the last cell of a shorter row still takes its column, the columns before it are left empty
+		last := -1
+		for col, s := range pending {
+			if s.remaining > 0 && col > last {
+				last = col
+			}
+		}
+		for len(row) <= last {
+			if s, found := pending[len(row)]; found && s.remaining > 0 {
+				row = append(row, s.cell.spanCopy())
+				s.remaining--
+				continue
+			}
+			row = append(row, &TableCell{RowSpan: 1, ColSpan: 1})
+		}
+		if len(row) > columns {
+			columns = len(row)
+		}
+		grid = append(grid, row)
+	}
+	for i := range grid {
+		for len(grid[i]) < columns {
+			grid[i] = append(grid[i], &TableCell{RowSpan: 1, ColSpan: 1})
+		}
+	}
+	return grid
+}
+
+func (c *TableCell) spanCopy() *TableCell {
+	cp := *c
+	cp.Spanned = true
+	return &cp
+}
+
+func spanAttribute(h *HtmlData, attribute string) int {
+	v, err := strconv.Atoi(strings.TrimSpace(h.Attributes[attribute]))
+	if err != nil || v < 1 {
+		return 1
+	}
+	// browsers cap spans, this also protects against huge values in broken pages
+	if v > 1000 {
+		return 1000
+	}
+	return v
+}
+
+func cellLinks(h *HtmlData, baseLink string) []string {
+	var links []string
+	for _, a := range h.GetTags([]string{"a"}) {
+		l, err := a.FindLinks(baseLink, []string{"href"})
+		if err != nil || l == "" {
+			l = a.Attributes["href"]
+		}
+		if l != "" {
+			links = append(links, l)
+		}
+	}
+	return links
+}
+
+// headerNames combines multiple header rows into one name per column, ie "Price Min"
+func headerNames(rows [][]*TableCell) []string {
+	if len(rows) == 0 {
+		return nil
+	}
+	names := make([]string, len(rows[0]))
+	for i := range names {
+		var parts []string
+		for _, r := range rows {
+			if i >= len(r) || r[i].Text == "" {
+				continue
+			}
+			if len(parts) > 0 && parts[len(parts)-1] == r[i].Text {
+				continue
+			}
+			parts = append(parts, r[i].Text)
+		}
+		names[i] = strings.Join(parts, " ")
+	}
+	return names
+}
@@ -0,0 +1,255 @@
+package v2
+
+import (
+	"strconv"
+	"strings"
+)
+
+// contentPart is either a run of text or an element, kept in the order they appear in the source
+type contentPart struct {
+	text string
+	node *HtmlData
+}
+
+var (
+	// textBlockElements start and end on their own line
+	textBlockElements = map[string]struct{}{
+		"address": {}, "article": {}, "aside": {}, "blockquote": {}, "body": {}, "caption": {}, "center": {},
+		"dd": {}, "details": {}, "dialog": {}, "dir": {}, "div": {}, "dl": {}, "dt": {}, "fieldset": {},
+		"figcaption": {}, "figure": {}, "footer": {}, "form": {}, "h1": {}, "h2": {}, "h3": {}, "h4": {},
+		"h5": {}, "h6": {}, "header": {}, "hgroup": {}, "hr": {}, "html": {}, "legend": {}, "li": {},
+		"main": {}, "menu": {}, "nav": {}, "ol": {}, "option": {}, "p": {}, "pre": {}, "section": {},
+		"summary": {}, "table": {}, "tbody": {}, "tfoot": {}, "thead": {}, "tr": {}, "ul": {},
+	}
+	// textHiddenElements are never rendered so their text is skipped
+	textHiddenElements = map[string]struct{}{
+		"head": {}, "noscript": {}, "script": {}, "style": {}, "template": {},
+	}
+)
+
+// InnerText returns the readable text of the element laid out the way a browser would render it
+// block elements are put on their own lines, paragraphs are separated by a blank line, <br> breaks the line,
+// whitespace is collapsed, list items get a bullet and script/style/noscript content is skipped
+func (h *HtmlData) InnerText() string {
+	t := &textBuilder{}
+	h.writeText(t, nil)
+	return strings.ReplaceAll(t.b.String(), "\u00a0", " ")
+}
+
+// Text returns the InnerText of the element on a single line
+func (h *HtmlData) Text() string {
+	return strings.Join(strings.Fields(h.InnerText()), " ")
+}
+
+// parts returns the text and elements of the node in source order
+// nodes built by hand, or whose Child and Sibling were changed after parsing, have no recorded order
+// so their text comes first followed by children and siblings
+func (h *HtmlData) parts() []contentPart {
+	if h.content != nil && h.contentValid() {
+		return h.content
+	}
+	var p []contentPart
+	if h.TextData != "" {
+		p = append(p, contentPart{text: h.TextData})
+	}
+	for _, c := range h.Child {
+		p = append(p, contentPart{node: c})
+	}
+	for _, s := range h.Sibling {
+		p = append(p, contentPart{node: s})
+	}
+	return p
+}
+
+// contentValid reports whether the recorded order still holds exactly the elements of Child and Sibling
+// parsing, Clone and Remove keep both lists in source order so the recorded elements have to be the two lists merged
+func (h *HtmlData) contentValid() bool {
+	c, s := 0, 0
+	for _, p := range h.content {
+		switch {
+		case p.node == nil:
+		case c < len(h.Child) && h.Child[c] == p.node:
+			c++
+		case s < len(h.Sibling) && h.Sibling[s] == p.node:
+			s++
+		default:
+			return false
+		}
+	}
+	return c == len(h.Child) && s == len(h.Sibling)
+}
+
+func (h *HtmlData) isHidden() bool {
+	if _, found := textHiddenElements[strings.ToLower(h.Tag)]; found {
+		return true
+	}
+	if _, found := h.Attributes["hidden"]; found {
+		return true
+	}
+	return strings.Contains(strings.ReplaceAll(h.Attributes["style"], " ", ""), "display:none")
+}
+
+// listState tracks the numbering of the closest list while writing text
+type listState struct {
+	ordered bool
+	count   int
+}
+
+func (h *HtmlData) writeText(t *textBuilder, list *listState) {
+	tag := strings.ToLower(h.Tag)
+	_, block := textBlockElements[tag]
+	switch {
+	case tag == "p":
+		t.lineBreak(2)
+	case block:
+		t.lineBreak(1)
+	}
+	switch tag {
+	case "br":
+		t.newLine()
+		return
+	case "ol":
+		list = &listState{ordered: true}
+		if start, err := strconv.Atoi(h.Attributes["start"]); err == nil {
+			list.count = start - 1
+		}
+	case "ul", "menu", "dir":
+		list = &listState{}
+	case "li":
+		if list != nil && list.ordered {
+			list.count++
+			t.prefix(strconv.Itoa(list.count) + ". ")
+		} else {
+			t.prefix("• ")
+		}
+	case "td", "th":
+		t.cell()
+	case "pre", "textarea":
+		t.pre++
+		defer func() { t.pre-- }()
+	}
+
+	for _, p := range h.parts() {
+		if p.node == nil {
+			t.text(p.text)
+			continue
+		}
+		if p.node.isHidden() {
+			continue
+		}
+		p.node.writeText(t, list)
+	}
+
+	switch {
+	case tag == "p":
+		t.lineBreak(2)
+	case block:
+		t.lineBreak(1)
+	case tag == "tr":
+		t.lineBreak(1)
+	}
+	switch tag {
+	case "td", "th":
+		t.endCell()
+	case "li":
+		t.pending = ""
+	}
+}
+
+// textBuilder lays out text lazily so line breaks and spaces only get written between two pieces of text
+type textBuilder struct {
+	b         strings.Builder
+	started   bool
+	breaks    int
+	separator string
+	pending   string
+	pre       int
+	inRow     bool
+}
+
+func (t *textBuilder) lineBreak(n int) {
+	if n > t.breaks {
+		t.breaks = n
+	}
+	t.separator = ""
+	t.inRow = false
+}
+
+func (t *textBuilder) newLine() {
+	t.flush()
+	t.b.WriteString("\n")
+	t.started = true
+	t.separator = ""
+}
+
+func (t *textBuilder) prefix(p string) {
+	t.pending = p
+}
+
+func (t *textBuilder) cell() {
+	if t.inRow {
+		t.separator = "\t"
+	}
+}
+
+func (t *textBuilder) endCell() {
+	t.inRow = true
+}
+
+// flush writes any line breaks or separators waiting for the next piece of text
+func (t *textBuilder) flush() {
+	if t.started {
+		if t.breaks > 0 {
+			t.b.WriteString(strings.Repeat("\n", t.breaks))
+		} else if t.separator != "" {
+			t.b.WriteString(t.separator)
+		}
+	}
+	t.breaks = 0
+	t.separator = ""
+	if t.pending != "" {
+		t.b.WriteString(t.pending)
+		t.pending = ""
+	}
+}
+
+func (t *textBuilder) text(s string) {
+	if s == "" {
+		return
+	}
+	if t.pre > 0 {
+		t.flush()
+		t.b.WriteString(s)
+		t.started = true
+		return
+	}
+	words := strings.FieldsFunc(s, isCollapsibleSpace)
+	if len(words) == 0 {
+		t.space()
+		return
+	}
+	if isCollapsibleSpace(rune(s[0])) {
+		t.space()
+	}
+	t.flush()
+	t.b.WriteString(strings.Join(words, " "))
+	t.started = true
+	if isCollapsibleSpace(rune(s[len(s)-1])) {
+		t.space()
+	}
+}
+
+func (t *textBuilder) space() {
+	if t.separator == "" {
+		t.separator = " "
+	}
+}
+
+// isCollapsibleSpace matches the whitespace a browser collapses, non-breaking spaces are kept
+func isCollapsibleSpace(r rune) bool {
+	switch r {
+	case ' ', '\t', '\n', '\r', '\f':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,362 @@
+package website
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+)
+
+// DecodeError is a field that could not be decoded, Path is the Go path of the field, ie Book.Chapters[2].URL
+type DecodeError struct {
+	Path     string
+	Selector string
+	Message  string
+}
+
+func (e *DecodeError) Error() string {
+	if e.Selector == "" {
+		return fmt.Sprintf("website: %s: %s", e.Path, e.Message)
+	}
+	return fmt.Sprintf("website: %s (select %q): %s", e.Path, e.Selector, e.Message)
+}
+
+// decodeField is a struct field with its tags parsed
+type decodeField struct {
+	index      []int
+	name       string
+	source     string
+	selector   *v2.Selector
+	attr       string
+	transforms []*Transform
+	required   bool
+	layout     string
+}
+
+var (
+	decodeFields sync.Map // reflect.Type -> []*decodeField
+	htmlDataType = reflect.TypeOf(&v2.HtmlData{})
+	timeType     = reflect.TypeOf(time.Time{})
+	urlType      = reflect.TypeOf(url.URL{})
+)
+
+// Unmarshal decodes a page into v, a pointer to a struct or to a slice of structs, a slice gets an element for every
+// element of the page matching rootSelector and a struct is decoded from the first one, the page itself is the root
+// when rootSelector is empty
+//
+// exported fields are read from the tags
+//
+//	select:"h1.title"         css selector relative to the parent element, the parent element itself when missing
+//	attr:"href"               attribute holding the value, text by default, own_text and inner_text are also known
+//	transform:"trim,int"      pipeline applied to the value, see ParseTransforms
+//	required:"true"           missing values are an error instead of the zero value
+//	layout:"2006-01-02"       time.Parse layout of time.Time fields, common layouts are tried when missing
+//
+// fields without any of these tags are skipped, slices get a value for every match, struct fields are decoded
+// from the matched element, pointers stay nil when nothing matches and *v2.HtmlData fields get the element itself
+func Unmarshal(page *v2.HtmlData, pageURL, rootSelector string, v interface{}) error {
+	var roots []*v2.HtmlData
+	if rootSelector == "" {
+		roots = []*v2.HtmlData{page}
+	} else {
+		var err error
+		if roots, err = page.Select(rootSelector); err != nil {
+			return err
+		}
+	}
+	return decodeRoots(roots, pageURL, v)
+}
+
+// DecodePage runs the parser over a loaded page and decodes the results into v, see Unmarshal
+// the roots are the elements of Schema.Selector when set, the results of the search list or else the page
+func (wp *Parser) DecodePage(page *v2.HtmlData, pageURL string, v interface{}) error {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return err
+	}
+	roots, err := wp.roots(page, u)
+	if err != nil {
+		return err
+	}
+	return decodeRoots(roots, pageURL, v)
+}
+
+// ParseInto requests the page and decodes it into v, see DecodePage
+// like Parse the url has to match the patterns of the parser, cancelling ctx stops the request
+func (wp *Parser) ParseInto(ctx context.Context, sourceReq *v2.HTMLSourceRequest, pageURL string, v interface{}) error {
+	if !wp.MatchURL(pageURL) {
+		return fmt.Errorf("parser %s does not handle %s", wp.Name, pageURL)
+	}
+	page, err := sourceReq.GetSourceCodeContext(ctx, pageURL, http.MethodGet, nil)
+	if err != nil {
+		return err
+	}
+	return wp.DecodePage(page, pageURL, v)
+}
+
+func decodeRoots(roots []*v2.HtmlData, pageURL string, v interface{}) error {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("website: decode needs a non nil pointer, got %T", v)
+	}
+	rv = rv.Elem()
+	switch {
+	case rv.Kind() == reflect.Struct:
+		if len(roots) == 0 {
+			return &DecodeError{Path: rv.Type().Name(), Message: "no element to decode"}
+		}
+		return decodeStruct(roots[0], rv, u, rv.Type().Name())
+	case rv.Kind() == reflect.Slice && isStruct(rv.Type().Elem()):
+		name := elemType(rv.Type().Elem()).Name()
+		items := reflect.MakeSlice(rv.Type(), 0, len(roots))
+		for i, r := range roots {
+			item := reflect.New(rv.Type().Elem()).Elem()
+			if _, err := decodeValue(r, item, &decodeField{}, u, fmt.Sprintf("%s[%d]", name, i)); err != nil {
+				return err
+			}
+			items = reflect.Append(items, item)
+		}
+		rv.Set(items)
+		return nil
+	}
+	return fmt.Errorf("website: decode needs a pointer to a struct or a slice of structs, got %T", v)
+}
+
+func elemType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func isStruct(t reflect.Type) bool {
+	t = elemType(t)
+	return t.Kind() == reflect.Struct && t != timeType && t != urlType
+}
+
+// fieldsOf returns the decoded fields of a struct type, embedded structs without tags are decoded inline
+func fieldsOf(t reflect.Type) ([]*decodeField, error) {
+	if cached, found := decodeFields.Load(t); found {
+		return cached.([]*decodeField), nil
+	}
+	var fields []*decodeField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		source, hasSelect := sf.Tag.Lookup("select")
+		attr, hasAttr := sf.Tag.Lookup("attr")
+		transform, hasTransform := sf.Tag.Lookup("transform")
+		if !hasSelect && !hasAttr && !hasTransform {
+			if sf.Anonymous && elemType(sf.Type).Kind() == reflect.Struct && sf.Type.Kind() != reflect.Ptr {
+				inner, err := fieldsOf(sf.Type)
+				if err != nil {
+					return nil, err
+				}
+				for _, f := range inner {
+					c := *f
+					c.index = append([]int{i}, f.index...)
+					fields = append(fields, &c)
+				}
+			}
+			continue
+		}
+		if sf.PkgPath != "" {
+			return nil, fmt.Errorf("website: %s.%s has decode tags but is not exported", t.Name(), sf.Name)
+		}
+		f := &decodeField{index: []int{i}, name: sf.Name, source: source, attr: attr, layout: sf.Tag.Get("layout")}
+		f.required, _ = strconv.ParseBool(sf.Tag.Get("required"))
+		if source != "" {
+			s, err := v2.CompileSelector(source)
+			if err != nil {
+				return nil, fmt.Errorf("website: %s.%s: %v", t.Name(), sf.Name, err)
+			}
+			f.selector = s
+		}
+		pipeline, err := ParseTransforms(transform)
+		if err != nil {
+			return nil, fmt.Errorf("website: %s.%s: %v", t.Name(), sf.Name, err)
+		}
+		f.transforms = pipeline
+		fields = append(fields, f)
+	}
+	decodeFields.Store(t, fields)
+	return fields, nil
+}
+
+func decodeStruct(n *v2.HtmlData, v reflect.Value, u *url.URL, path string) error {
+	fields, err := fieldsOf(v.Type())
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		matches := []*v2.HtmlData{n}
+		if f.selector != nil {
+			matches = n.SelectCompiled(f.selector)
+		}
+		if err := decodeMatches(matches, v.FieldByIndex(f.index), f, u, path+"."+f.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeMatches(matches []*v2.HtmlData, v reflect.Value, f *decodeField, u *url.URL, path string) error {
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		items := reflect.MakeSlice(v.Type(), 0, len(matches))
+		for i, m := range matches {
+			item := reflect.New(v.Type().Elem()).Elem()
+			ok, err := decodeValue(m, item, f, u, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return err
+			}
+			if ok {
+				items = reflect.Append(items, item)
+			}
+		}
+		if f.required && items.Len() == 0 {
+			return &DecodeError{Path: path, Selector: f.source, Message: "required value is missing"}
+		}
+		v.Set(items)
+		return nil
+	}
+	ok := false
+	if len(matches) > 0 {
+		var err error
+		if ok, err = decodeValue(matches[0], v, f, u, path); err != nil {
+			return err
+		}
+	}
+	if !ok && f.required {
+		return &DecodeError{Path: path, Selector: f.source, Message: "required value is missing"}
+	}
+	return nil
+}
+
+// decodeValue decodes a single element into v and reports whether a value was found
+func decodeValue(n *v2.HtmlData, v reflect.Value, f *decodeField, u *url.URL, path string) (bool, error) {
+	switch {
+	case v.Type() == htmlDataType:
+		v.Set(reflect.ValueOf(n))
+		return true, nil
+	case v.Kind() == reflect.Ptr:
+		item := reflect.New(v.Type().Elem())
+		ok, err := decodeValue(n, item.Elem(), f, u, path)
+		if ok && err == nil {
+			v.Set(item)
+		}
+		return ok, err
+	case isStruct(v.Type()):
+		return true, decodeStruct(n, v, u, path)
+	}
+	s := elementValue(n, f.attr, u, v.Type() == urlType)
+	s, err := ApplyTransforms(s, f.transforms, u)
+	if err != nil {
+		return false, &DecodeError{Path: path, Selector: f.source, Message: err.Error()}
+	}
+	if s == "" {
+		return false, nil
+	}
+	if err := setScalar(v, s, f.layout, u); err != nil {
+		return false, &DecodeError{Path: path, Selector: f.source, Message: err.Error()}
+	}
+	return true, nil
+}
+
+func setScalar(v reflect.Value, s, layout string, u *url.URL) error {
+	switch v.Type() {
+	case timeType:
+		layouts := schemaTimeLayouts
+		if layout != "" {
+			layouts = []string{layout}
+		}
+		for _, l := range layouts {
+			if t, err := time.Parse(l, s); err == nil {
+				v.Set(reflect.ValueOf(t))
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not a time", s)
+	case urlType:
+		parsed, err := u.Parse(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(*parsed))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		// numbers are read like the number transform reads them, the int transform drops the decimals
+		n, ok := integerText(s)
+		i, err := strconv.ParseInt(n, 10, v.Type().Bits())
+		if !ok || err != nil {
+			return fmt.Errorf("%q is not an integer, the int transform drops the decimals", s)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := integerText(s)
+		i, err := strconv.ParseUint(n, 10, v.Type().Bits())
+		if !ok || err != nil {
+			return fmt.Errorf("%q is not an unsigned integer, the int transform drops the decimals", s)
+		}
+		v.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, ok := numberValue(s)
+		if !ok {
+			return fmt.Errorf("%q is not a number", s)
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, found := convertValue(FieldBool, s, "").(bool)
+		if !found {
+			return fmt.Errorf("%q is not a bool", s)
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// elementValue reads an attribute of the element, text is the readable text, own_text the text of the element itself
+// and inner_text the text with its line breaks, an empty attr is the text or the first link attribute when link is set
+func elementValue(n *v2.HtmlData, attr string, u *url.URL, link bool) string {
+	var v string
+	switch strings.ToLower(attr) {
+	case "":
+		if !link {
+			return n.Text()
+		}
+		for _, a := range linkAttributes {
+			if v = strings.TrimSpace(n.Attributes[a]); v != "" {
+				break
+			}
+		}
+	case "text":
+		v = n.Text()
+	case "own_text":
+		v = strings.TrimSpace(n.TextData)
+	case "inner_text":
+		v = n.InnerText()
+	default:
+		v = strings.TrimSpace(n.Attributes[attr])
+	}
+	if link && v != "" {
+		if resolved, err := u.Parse(v); err == nil {
+			return resolved.String()
+		}
+	}
+	return v
+}
@@ -0,0 +1,76 @@
+package website
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+)
+
+func TestParseInto(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}
+		fmt.Fprint(w, `<html><body><h1 class="title">One</h1></body></html>`)
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	wp := &Parser{Name: "books", WebsiteURL: srv.URL}
+	var book struct {
+		Title string `select:"h1.title"`
+	}
+	if err := wp.ParseInto(context.Background(), v2.NewHTMLSourceRequest(), srv.URL+"/book", &book); err != nil {
+		t.Fatal(err)
+	}
+	if book.Title != "One" {
+		t.Errorf("title = %q, want One", book.Title)
+	}
+
+	err := wp.ParseInto(context.Background(), v2.NewHTMLSourceRequest(), "https://other.example.com/book", &book)
+	if err == nil || !strings.Contains(err.Error(), "does not handle") {
+		t.Errorf("err = %v, want the url to be rejected", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = wp.ParseInto(ctx, v2.NewHTMLSourceRequest(), srv.URL+"/slow", &book)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestUnmarshalNumbers(t *testing.T) {
+	page, err := v2.NewHTMLSourceRequest().ProcessSourceCode(`<html><body><p class="views">1,234 views</p>` +
+		`<p class="rating">4,5</p><p class="price">12.9</p></body></html>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v struct {
+		Views  int     `select:"p.views"`
+		Rating float64 `select:"p.rating"`
+	}
+	if err := Unmarshal(page, "https://example.com/", "", &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Views != 1234 || v.Rating != 4.5 {
+		t.Errorf("views = %d, rating = %v, want 1234 and 4.5", v.Views, v.Rating)
+	}
+	var price struct {
+		Price int `select:"p.price"`
+	}
+	if err := Unmarshal(page, "https://example.com/", "", &price); err == nil {
+		t.Errorf("price = %d, want an error for a number with decimals", price.Price)
+	}
+}
@@ -0,0 +1,634 @@
+package website
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+)
+
+// DefinitionVersion is the version of the site definition format read by ParseDefinition
+const DefinitionVersion = 1
+
+// Definition describes how to read a site, it is written as YAML or JSON and turned into a Parser
+//
+//	version: 1
+//	name: example
+//	website_url: https://example.com
+//	urls: ["https://example.com/series/**"]
+//	steps:
+//	  - tags: [div]
+//	    attributes: {class: "^chapters$"}
+//	    forward_data: true
+//	  - tags: [a]
+//	    remap: {text: title}
+//	    transforms: {title: "collapse, regex_replace('^Chapter\\s+', '')"}
+//	fields: {title: title, url: link}
+//	pagination: {next: [{tags: [a], attributes: {rel: "^next$"}}], max_pages: 10}
+//	fetch: {method: GET, sleep_max: 2}
+type Definition struct {
+	Version    int    `yaml:"version" json:"version"`
+	Name       string `yaml:"name" json:"name"`
+	WebsiteURL string `yaml:"website_url" json:"website_url"`
+	// URLs are the pages the definition applies to, see Parser.URLs, WebsiteURL is used when there are no patterns
+	URLs []string `yaml:"urls" json:"urls"`
+	// Hosts and Paths are host and path patterns of the pages the definition applies to, see Parser.Hosts
+	Hosts []string `yaml:"hosts" json:"hosts"`
+	Paths []string `yaml:"paths" json:"paths"`
+	// Priority picks between definitions matching the same page, see ParserRegistry.Lookup
+	Priority int `yaml:"priority" json:"priority"`
+	// Steps run in order, every step searches the result of the previous step when it forwards its data
+	Steps []*Step `yaml:"steps" json:"steps"`
+	// Fields maps the output names to the keys of the remapped output, every key is kept when empty
+	Fields map[string]string `yaml:"fields" json:"fields"`
+	// Schema shapes the output into nested typed records, see Parser.Extract
+	Schema     *Schema               `yaml:"schema" json:"schema"`
+	Pagination *DefinitionPagination `yaml:"pagination" json:"pagination"`
+	Fetch      *Fetch                `yaml:"fetch" json:"fetch"`
+
+	// File is the file the definition was read from
+	File string `yaml:"-" json:"-"`
+}
+
+// Step is one search order of a Parser, tags are OR'd and attributes are regex expressions
+type Step struct {
+	Tags       []string          `yaml:"tags" json:"tags"`
+	Attributes map[string]string `yaml:"attributes" json:"attributes"`
+	// Remap renames keys of the remapped output, ie {href: url} or {text: title}
+	Remap map[string]string `yaml:"remap" json:"remap"`
+	// Transforms are the pipelines of the keys of the remapped output, after Remap, see ParseTransforms
+	Transforms   map[string]string `yaml:"transforms" json:"transforms"`
+	ForwardData  bool              `yaml:"forward_data" json:"forward_data"`
+	UseChildData bool              `yaml:"use_child_data" json:"use_child_data"`
+	Flatten      bool              `yaml:"flatten" json:"flatten"`
+	SkipRemap    bool              `yaml:"skip_remap" json:"skip_remap"`
+	// Scope is where the step searches inside the matches of a forwarding step, see Search.Scope
+	Scope string `yaml:"scope" json:"scope"`
+}
+
+// DefinitionPagination finds the next page of a site
+type DefinitionPagination struct {
+	// Next are the steps finding the link to the next page, the first link found is used
+	Next []*Step `yaml:"next" json:"next"`
+	// MaxPages limits the pages FollowPages loads, 0 is no limit
+	MaxPages int `yaml:"max_pages" json:"max_pages"`
+}
+
+// Fetch is how the pages of a site are requested
+//
+//	fetch:
+//	  method: POST
+//	  body: '{"query": {{json .Params.query}}, "page": {{.Query.Get "page" | json}}}'
+type Fetch struct {
+	Method string `yaml:"method" json:"method"`
+	// Body is a text/template rendered for every request, see RenderBody
+	Body string `yaml:"body" json:"body"`
+	// Headers are sent with every request, the Content-Type of a body is json or a form when it is not set
+	Headers map[string]string `yaml:"headers" json:"headers"`
+	// SleepMax is the most seconds to wait after a request, see HTMLSourceRequest.SleepTimeMax
+	SleepMax int `yaml:"sleep_max" json:"sleep_max"`
+	// PathIDs gives nodes ids derived from their path so they are the same between requests
+	PathIDs bool `yaml:"path_ids" json:"path_ids"`
+}
+
+// DefinitionError is a problem found at a line of a definition file
+type DefinitionError struct {
+	File   string
+	Line   int
+	Column int
+	// Field is the path of the field, ie steps[1].attributes
+	Field   string
+	Message string
+}
+
+func (e *DefinitionError) Error() string {
+	position := e.File
+	if e.Line > 0 {
+		position = fmt.Sprintf("%s:%d:%d", e.File, e.Line, e.Column)
+	}
+	if e.Field == "" {
+		return fmt.Sprintf("%s: %s", position, e.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", position, e.Field, e.Message)
+}
+
+// DefinitionErrors are all the problems found while loading definitions
+type DefinitionErrors []*DefinitionError
+
+func (e DefinitionErrors) Error() string {
+	var lines []string
+	for _, err := range e {
+		lines = append(lines, err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+var (
+	yamlLine    = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+	yamlTypeErr = regexp.MustCompile(`^line (\d+): (.*)$`)
+	httpMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead}
+)
+
+// ParseDefinition reads a YAML or JSON definition and validates it, file is only used in errors
+// errors are DefinitionErrors holding the line and column of every problem
+func ParseDefinition(data []byte, file string) (*Definition, error) {
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		// json is read as yaml to keep line numbers, tabs are only valid between json tokens so they can become spaces
+		data = bytes.ReplaceAll(data, []byte("\t"), []byte(" "))
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, DefinitionErrors{yamlError(file, err)}
+	}
+	d := &Definition{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(d); err != nil {
+		var errs DefinitionErrors
+		if typeErr, ok := err.(*yaml.TypeError); ok {
+			for _, e := range typeErr.Errors {
+				errs = append(errs, yamlError(file, fmt.Errorf("%s", e)))
+			}
+			return nil, errs
+		}
+		return nil, DefinitionErrors{yamlError(file, err)}
+	}
+	d.File = file
+	if errs := d.validate(&root); len(errs) > 0 {
+		return nil, errs
+	}
+	if _, err := d.Parser(); err != nil {
+		n := nodeAt(&root, "steps")
+		return nil, DefinitionErrors{{File: file, Line: n.Line, Column: n.Column, Field: "steps", Message: err.Error()}}
+	}
+	return d, nil
+}
+
+func yamlError(file string, err error) *DefinitionError {
+	msg := err.Error()
+	for _, re := range []*regexp.Regexp{yamlLine, yamlTypeErr} {
+		if m := re.FindStringSubmatch(msg); m != nil {
+			line := 0
+			fmt.Sscanf(m[1], "%d", &line)
+			return &DefinitionError{File: file, Line: line, Column: 1, Message: m[2]}
+		}
+	}
+	return &DefinitionError{File: file, Message: msg}
+}
+
+// LoadDefinition reads a definition file, files ending in .json are read as JSON and every other file as YAML
+func LoadDefinition(file string) (*Definition, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDefinition(data, file)
+}
+
+// LoadDefinitions reads every .yaml, .yml and .json file of a directory in name order
+// the errors of every file are returned together so a bad directory can be fixed in one go
+func LoadDefinitions(dir string) ([]*Definition, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".yaml", ".yml", ".json":
+			if !e.IsDir() {
+				files = append(files, filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+	sort.Strings(files)
+	var definitions []*Definition
+	var errs DefinitionErrors
+	names := map[string]string{}
+	for _, f := range files {
+		d, err := LoadDefinition(f)
+		if err != nil {
+			if de, ok := err.(DefinitionErrors); ok {
+				errs = append(errs, de...)
+				continue
+			}
+			return nil, err
+		}
+		if other, found := names[d.Name]; found {
+			errs = append(errs, &DefinitionError{File: f, Field: "name", Message: fmt.Sprintf("%q is already defined in %s", d.Name, other)})
+			continue
+		}
+		names[d.Name] = f
+		definitions = append(definitions, d)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return definitions, nil
+}
+
+// LoadParsers reads a directory of definitions and returns their parsers
+func LoadParsers(dir string) ([]*Parser, error) {
+	definitions, err := LoadDefinitions(dir)
+	if err != nil {
+		return nil, err
+	}
+	var parsers []*Parser
+	for _, d := range definitions {
+		p, err := d.Parser()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", d.File, err)
+		}
+		parsers = append(parsers, p)
+	}
+	return parsers, nil
+}
+
+// Parser builds the parser of the definition, every step becomes the search order of its index
+func (d *Definition) Parser() (*Parser, error) {
+	p := &Parser{
+		ID:         uuid.New().String(),
+		Name:       d.Name,
+		WebsiteURL: d.websiteURL(),
+		URLs:       d.URLs,
+		Hosts:      d.Hosts,
+		Paths:      d.Paths,
+		Priority:   d.Priority,
+		SearchList: stepSearches(d.Steps),
+		Schema:     d.Schema,
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func stepSearches(steps []*Step) []*Search {
+	var searches []*Search
+	for order, s := range steps {
+		base := Search{
+			Order:        order,
+			ForwardData:  s.ForwardData,
+			UseChildData: s.UseChildData,
+			Scope:        s.Scope,
+			Flatten:      s.Flatten,
+			SkipRemap:    s.SkipRemap,
+		}
+		for _, tag := range s.Tags {
+			search := base
+			search.Type, search.Tag = TypeTag, tag
+			searches = append(searches, &search)
+		}
+		for _, k := range sortedStrings(s.Attributes) {
+			search := base
+			search.Type, search.Tag, search.TagValue = TypeAttribute, k, s.Attributes[k]
+			searches = append(searches, &search)
+		}
+		for _, k := range sortedStrings(s.Remap) {
+			searches = append(searches, &Search{Type: TypeAttribute, Tag: k, InternalTagName: s.Remap[k], Order: order, OnlyRemap: true})
+		}
+		for _, k := range sortedStrings(s.Transforms) {
+			searches = append(searches, &Search{Type: TypeAttribute, Tag: k, Transform: s.Transforms[k], Order: order, OnlyRemap: true})
+		}
+	}
+	return searches
+}
+
+func sortedStrings(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (d *Definition) websiteURL() string {
+	if d.WebsiteURL != "" || len(d.URLs) == 0 {
+		return d.WebsiteURL
+	}
+	u, err := url.Parse(d.URLs[0])
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s://%s", u.Scheme, strings.TrimPrefix(u.Host, "*."))
+}
+
+// Request returns a source request using the fetch settings of the definition
+func (d *Definition) Request() *v2.HTMLSourceRequest {
+	r := v2.NewHTMLSourceRequest()
+	if d.Fetch != nil {
+		r.SleepTimeMax = d.Fetch.SleepMax
+		if d.Fetch.PathIDs {
+			r.IDMode = v2.PathIDs
+		}
+		r.Header = d.Fetch.header()
+	}
+	return r
+}
+
+// Parse requests a page with the fetch settings and returns the fields of every remapped row
+func (d *Definition) Parse(req *v2.HTMLSourceRequest, pageURL string) ([]map[string]string, error) {
+	return d.ParseWith(req, pageURL, nil)
+}
+
+// ParseWith is Parse with the params of the body template, see RenderBody
+func (d *Definition) ParseWith(req *v2.HTMLSourceRequest, pageURL string, params map[string]string) ([]map[string]string, error) {
+	page, err := d.load(context.Background(), req, pageURL, params)
+	if err != nil {
+		return nil, err
+	}
+	return d.ParseDocument(page, pageURL)
+}
+
+// FollowPages parses pageURL and every next page found by Pagination, calling fn with the rows of each of them
+// it stops after Pagination.MaxPages pages (0 for no limit), see PageLoop, cancelling ctx also stops the page being loaded
+func (d *Definition) FollowPages(ctx context.Context, req *v2.HTMLSourceRequest, pageURL string, params map[string]string,
+	fn func(pageURL string, rows []map[string]string) error) error {
+	loop := &PageLoop{
+		Load: func(ctx context.Context, pageURL string) (*v2.HtmlData, error) {
+			return d.load(ctx, req, pageURL, params)
+		},
+		Next: d.NextPage,
+	}
+	if d.Pagination != nil {
+		loop.MaxPages = d.Pagination.MaxPages
+	}
+	return loop.Run(ctx, pageURL, func(pageURL string, page *v2.HtmlData) error {
+		rows, err := d.ParseDocument(page, pageURL)
+		if err != nil {
+			return err
+		}
+		return fn(pageURL, rows)
+	})
+}
+
+// load requests a page with the fetch settings
+func (d *Definition) load(ctx context.Context, req *v2.HTMLSourceRequest, pageURL string, params map[string]string) (*v2.HtmlData, error) {
+	method := http.MethodGet
+	if d.Fetch != nil && d.Fetch.Method != "" {
+		method = strings.ToUpper(d.Fetch.Method)
+	}
+	body, err := d.RenderBody(pageURL, params)
+	if err != nil {
+		return nil, err
+	}
+	return req.GetSourceCodeContext(ctx, pageURL, method, body)
+}
+
+// ParseDocument returns the fields of every remapped row of a page that is already loaded, see Parser.ParseDocument
+func (d *Definition) ParseDocument(doc *v2.HtmlData, pageURL string) ([]map[string]string, error) {
+	parser, err := d.Parser()
+	if err != nil {
+		return nil, err
+	}
+	_, rows, err := parser.ParseDocument(doc, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	return d.Project(rows), nil
+}
+
+// ParseReader is ParseDocument for html read from r
+func (d *Definition) ParseReader(r io.Reader, pageURL string) ([]map[string]string, error) {
+	doc, err := d.Request().ProcessReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return d.ParseDocument(doc, pageURL)
+}
+
+// Project keeps the Fields of every row under their output names, rows are returned as they are when there are no fields
+func (d *Definition) Project(rows []map[string]string) []map[string]string {
+	if len(d.Fields) == 0 {
+		return rows
+	}
+	var output []map[string]string
+	for _, r := range rows {
+		projected := map[string]string{}
+		for name, key := range d.Fields {
+			if v, found := r[key]; found {
+				projected[name] = v
+			}
+		}
+		if len(projected) > 0 {
+			output = append(output, projected)
+		}
+	}
+	return output
+}
+
+// NextPage returns the absolute url of the next page or an empty string when there is none
+func (d *Definition) NextPage(page *v2.HtmlData, pageURL string) string {
+	if d.Pagination == nil || len(d.Pagination.Next) == 0 {
+		return ""
+	}
+	p := &Parser{Name: d.Name, SearchList: stepSearches(d.Pagination.Next)}
+	nodes, _, err := p.ParseDocument(page, pageURL)
+	if err != nil {
+		return ""
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	for _, n := range nodes {
+		for _, attr := range []string{"href", "data-href", "value"} {
+			href := strings.TrimSpace(n.Attributes[attr])
+			if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+				continue
+			}
+			// relative links like ?page=2 are common for pagination, FindLinks only resolves links starting with /
+			if u, err := base.Parse(href); err == nil && u.String() != pageURL {
+				return u.String()
+			}
+		}
+	}
+	return ""
+}
+
+// MatchURL reports whether the definition applies to pageURL, see Parser.MatchURL
+func (d *Definition) MatchURL(pageURL string) bool {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return false
+	}
+	_, ok := matchURL(d.WebsiteURL, d.URLs, d.Hosts, d.Paths, u)
+	return ok
+}
+
+// validate checks the decoded definition, root is the yaml tree of the file used to find the line of every problem
+func (d *Definition) validate(root *yaml.Node) DefinitionErrors {
+	var errs DefinitionErrors
+	fail := func(message string, field ...interface{}) {
+		n := nodeAt(root, field...)
+		errs = append(errs, &DefinitionError{File: d.File, Line: n.Line, Column: n.Column, Field: fieldPath(field), Message: message})
+	}
+	switch {
+	case d.Version == 0:
+		fail("version is required, the current version is " + fmt.Sprint(DefinitionVersion))
+	case d.Version != DefinitionVersion:
+		fail(fmt.Sprintf("unsupported version %d, expected %d", d.Version, DefinitionVersion), "version")
+	}
+	if strings.TrimSpace(d.Name) == "" {
+		fail("name is required", "name")
+	}
+	if d.WebsiteURL == "" && len(d.URLs) == 0 {
+		fail("website_url or urls is required")
+	}
+	if d.WebsiteURL != "" {
+		if u, err := url.Parse(d.WebsiteURL); err != nil || u.Scheme == "" || u.Host == "" {
+			fail(fmt.Sprintf("%q is not an absolute url", d.WebsiteURL), "website_url")
+		}
+	}
+	for i, pattern := range d.URLs {
+		for _, problem := range validatePatterns([]string{pattern}, nil, nil) {
+			fail(problem, "urls", i)
+		}
+	}
+	for i, pattern := range d.Hosts {
+		for _, problem := range validatePatterns(nil, []string{pattern}, nil) {
+			fail(problem, "hosts", i)
+		}
+	}
+	for i, pattern := range d.Paths {
+		for _, problem := range validatePatterns(nil, nil, []string{pattern}) {
+			fail(problem, "paths", i)
+		}
+	}
+	if len(d.Steps) == 0 && d.Schema == nil {
+		fail("at least one step or a schema is required", "steps")
+	}
+	if d.Schema != nil {
+		if err := d.Schema.Compile(); err != nil {
+			fail(err.Error(), "schema")
+		}
+	}
+	validateSteps(d.Steps, "steps", fail)
+	for name, key := range d.Fields {
+		if strings.TrimSpace(key) == "" {
+			fail("the key of the field is empty", "fields", name)
+		}
+	}
+	if d.Pagination != nil {
+		if d.Pagination.MaxPages < 0 {
+			fail("max_pages can not be negative", "pagination", "max_pages")
+		}
+		validateSteps(d.Pagination.Next, "next", func(message string, field ...interface{}) {
+			fail(message, append([]interface{}{"pagination"}, field...)...)
+		})
+	}
+	if d.Fetch != nil {
+		if d.Fetch.Method != "" && !isInList(strings.ToUpper(d.Fetch.Method), httpMethods) {
+			fail(fmt.Sprintf("unknown method %q, expected one of %s", d.Fetch.Method, strings.Join(httpMethods, ", ")), "fetch", "method")
+		}
+		if d.Fetch.SleepMax < 0 {
+			fail("sleep_max can not be negative", "fetch", "sleep_max")
+		}
+		if d.Fetch.Body != "" {
+			if method := strings.ToUpper(d.Fetch.Method); method == "" || method == http.MethodGet || method == http.MethodHead {
+				fail("a body needs a method like POST", "fetch", "method")
+			}
+			if _, err := d.Fetch.bodyTemplate(); err != nil {
+				fail(err.Error(), "fetch", "body")
+			}
+		}
+	}
+	return errs
+}
+
+func validateSteps(steps []*Step, field string, fail func(message string, field ...interface{})) {
+	for i, s := range steps {
+		if s == nil {
+			fail("empty step", field, i)
+			continue
+		}
+		if len(s.Tags) == 0 && len(s.Attributes) == 0 {
+			fail("a step needs tags or attributes", field, i)
+		}
+		for k, v := range s.Attributes {
+			if _, err := regexp.Compile(v); err != nil {
+				fail(fmt.Sprintf("invalid regex: %v", err), field, i, "attributes", k)
+			}
+		}
+		for k, v := range s.Transforms {
+			if _, err := ParseTransforms(v); err != nil {
+				fail(err.Error(), field, i, "transforms", k)
+			}
+		}
+		if s.Scope != "" && !isInList(s.Scope, []string{ScopeDescendants, ScopeChildren, ScopeSiblings}) {
+			fail(fmt.Sprintf("unknown scope %q, expected %s, %s or %s", s.Scope, ScopeDescendants, ScopeChildren, ScopeSiblings), field, i, "scope")
+		}
+		if s.ForwardData && i == len(steps)-1 {
+			fail("the last step has nothing to forward its data to", field, i, "forward_data")
+		}
+	}
+}
+
+func isInList(v string, list []string) bool {
+	for _, l := range list {
+		if l == v {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeAt returns the yaml node at a path of mapping keys and sequence indexes, or the deepest node found on the way
+func nodeAt(root *yaml.Node, field ...interface{}) *yaml.Node {
+	n := root
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		n = n.Content[0]
+	}
+	for _, f := range field {
+		next := (*yaml.Node)(nil)
+		switch key := f.(type) {
+		case string:
+			if n.Kind == yaml.MappingNode {
+				for i := 0; i+1 < len(n.Content); i += 2 {
+					if n.Content[i].Value == key {
+						next = n.Content[i+1]
+						break
+					}
+				}
+			}
+		case int:
+			if n.Kind == yaml.SequenceNode && key < len(n.Content) {
+				next = n.Content[key]
+			}
+		}
+		if next == nil {
+			return n
+		}
+		n = next
+	}
+	return n
+}
+
+func fieldPath(field []interface{}) string {
+	var b strings.Builder
+	for _, f := range field {
+		switch v := f.(type) {
+		case int:
+			fmt.Fprintf(&b, "[%d]", v)
+		default:
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			fmt.Fprint(&b, v)
+		}
+	}
+	return b.String()
+}
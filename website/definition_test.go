@@ -0,0 +1,90 @@
+package website
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+)
+
+func TestDefinitionFollowPages(t *testing.T) {
+	// page 3 links back to page 1 so the loop has to stop on the visited url
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		next := map[string]string{"": "?page=2", "2": "?page=3", "3": "/"}[page]
+		fmt.Fprintf(w, `<html><body><p class="item">item %s</p><a rel="next" href="%s">next</a></body></html>`, page, next)
+	}))
+	defer srv.Close()
+
+	for _, c := range []struct {
+		maxPages int
+		want     []string
+	}{
+		{0, []string{"item", "item 2", "item 3"}},
+		{2, []string{"item", "item 2"}},
+	} {
+		d, err := ParseDefinition([]byte(fmt.Sprintf(`version: 1
+name: pages
+website_url: %s
+steps:
+  - tags: [p]
+    attributes: {class: "^item$"}
+fields: {title: text}
+pagination: {next: [{tags: [a], attributes: {rel: "^next$"}}], max_pages: %d}
+`, srv.URL, c.maxPages)), "pages.yaml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		err = d.FollowPages(context.Background(), v2.NewHTMLSourceRequest(), srv.URL+"/", nil, func(pageURL string, rows []map[string]string) error {
+			for _, r := range rows {
+				got = append(got, r["title"])
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("max_pages %d: got %q, want %q", c.maxPages, got, c.want)
+		}
+	}
+}
+
+func TestDefinitionFollowPagesCancelsRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+		fmt.Fprint(w, `<html><body><p class="item">slow</p></body></html>`)
+	}))
+	defer srv.Close()
+
+	d, err := ParseDefinition([]byte(fmt.Sprintf(`version: 1
+name: slow
+website_url: %s
+steps:
+  - tags: [p]
+fields: {title: text}
+`, srv.URL)), "slow.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = d.FollowPages(ctx, v2.NewHTMLSourceRequest(), srv.URL+"/", nil, func(string, []map[string]string) error { return nil })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("FollowPages returned after %v, the request was not cancelled", elapsed)
+	}
+}
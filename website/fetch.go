@@ -0,0 +1,75 @@
+package website
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// BodyData is what the body template of a fetch is rendered with
+type BodyData struct {
+	URL  string
+	Host string
+	Path string
+	// Query are the query values of the page url, ie {{.Query.Get "page"}}
+	Query url.Values
+	// Params are the values given to ParseWith, a missing param is an error
+	Params map[string]string
+}
+
+// bodyFuncs are the functions of body templates besides the text/template ones like urlquery
+var bodyFuncs = template.FuncMap{
+	// json writes a value as a json literal, quotes and escapes included
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func (f *Fetch) bodyTemplate() (*template.Template, error) {
+	return template.New("body").Funcs(bodyFuncs).Option("missingkey=error").Parse(f.Body)
+}
+
+// RenderBody renders the body template of the fetch for a page, nil when the definition has no body
+func (d *Definition) RenderBody(pageURL string, params map[string]string) ([]byte, error) {
+	if d.Fetch == nil || d.Fetch.Body == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	t, err := d.Fetch.bodyTemplate()
+	if err != nil {
+		return nil, err
+	}
+	if params == nil {
+		params = map[string]string{}
+	}
+	var b bytes.Buffer
+	data := &BodyData{URL: pageURL, Host: u.Host, Path: u.Path, Query: u.Query(), Params: params}
+	if err := t.Execute(&b, data); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// header returns the headers of the fetch, a body starting like a json object or array is sent as json and any other
+// body as a form, a body starting with a template action is a form too so json ones need their Content-Type header
+func (f *Fetch) header() http.Header {
+	h := http.Header{}
+	for k, v := range f.Headers {
+		h.Set(k, v)
+	}
+	if body := strings.TrimSpace(f.Body); body != "" && h.Get("Content-Type") == "" {
+		if strings.HasPrefix(body, "[") || strings.HasPrefix(body, "{") && !strings.HasPrefix(body, "{{") {
+			h.Set("Content-Type", "application/json")
+		} else {
+			h.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	return h
+}
@@ -0,0 +1,404 @@
+package website
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+)
+
+// InferencePage is a loaded page together with the values a user expects to get from it
+type InferencePage struct {
+	URL  string
+	Page *v2.HtmlData
+	// Examples maps a field name to the values expected on this page, ie "title": {"Chapter 1"}
+	// values that look like links (http://, https:// or starting with /) are matched against href, src and data-src
+	Examples map[string][]string
+}
+
+// InferredField is the best search list found for a field and a report of how well it matched the examples
+type InferredField struct {
+	Name string `json:"name"`
+	// Searches is ready to use with NewWebParser, text or links are remapped to Name
+	Searches []*Search `json:"searches"`
+	// Selector is a readable form of Searches, ie div[class~=chapters] > a[href~=^/ch/[0-9]+]
+	Selector string `json:"selector"`
+	// Pages is the number of pages with examples for the field, Matched the number of them where every example was found
+	Pages   int `json:"pages"`
+	Matched int `json:"matched"`
+	// Recall is the share of examples found and Precision the share of found values that were examples, averaged over pages
+	Recall    float64  `json:"recall"`
+	Precision float64  `json:"precision"`
+	Missing   []string `json:"missing"`
+}
+
+const (
+	// inferAncestors is how far up from an example node a scoping container is looked for
+	inferAncestors = 4
+	// inferNodes is the number of example nodes per page used to build candidates
+	inferNodes = 5
+)
+
+var (
+	digitRun       = regexp.MustCompile(`[0-9]+`)
+	inferAttribute = []string{"id", "class", "itemprop", "property", "rel", "role", "name"}
+)
+
+type inferCandidate struct {
+	searches []*Search
+	selector string
+}
+
+type inferScore struct {
+	recall, precision float64
+	matched           int
+	missing           []string
+}
+
+// InferSearch finds a Search list for every field of the examples, nodes matching the example values are located on each page
+// and generalized into selectors built from their tag, id, class and link patterns, optionally scoped by a container with ForwardData
+// every selector is run against every page and the one finding the most examples with the fewest extra values is kept
+func InferSearch(pages []*InferencePage) ([]*InferredField, error) {
+	if len(pages) == 0 {
+		return nil, errors.New("no pages to infer from")
+	}
+	var fields []string
+	seen := map[string]struct{}{}
+	for _, p := range pages {
+		if p.Page == nil {
+			return nil, fmt.Errorf("page %s has no data", p.URL)
+		}
+		if _, err := url.Parse(p.URL); err != nil {
+			return nil, err
+		}
+		for f := range p.Examples {
+			if _, found := seen[f]; !found {
+				seen[f] = struct{}{}
+				fields = append(fields, f)
+			}
+		}
+	}
+	sort.Strings(fields)
+
+	var output []*InferredField
+	for _, f := range fields {
+		output = append(output, inferField(f, pages))
+	}
+	return output, nil
+}
+
+// CombineSearches joins the searches of several inferred fields into the list of a single Parser
+// fields scoped with ForwardData replace the page for the orders after them, so at most one of them can be combined
+func CombineSearches(fields []*InferredField) ([]*Search, error) {
+	var direct, scoped []*InferredField
+	for _, f := range fields {
+		if len(f.Searches) == 0 {
+			continue
+		}
+		forwards := false
+		for _, s := range f.Searches {
+			forwards = forwards || s.ForwardData
+		}
+		if forwards {
+			scoped = append(scoped, f)
+		} else {
+			direct = append(direct, f)
+		}
+	}
+	if len(scoped) > 1 {
+		return nil, fmt.Errorf("fields %s and %s both use ForwardData, use a parser per field", scoped[0].Name, scoped[1].Name)
+	}
+	var output []*Search
+	order := 0
+	for _, f := range append(direct, scoped...) {
+		last := 0
+		for _, s := range f.Searches {
+			c := *s
+			c.Order += order
+			if s.Order > last {
+				last = s.Order
+			}
+			output = append(output, &c)
+		}
+		order += last + 1
+	}
+	return output, nil
+}
+
+func inferField(name string, pages []*InferencePage) *InferredField {
+	field := &InferredField{Name: name}
+	links := true
+	for _, p := range pages {
+		for _, v := range p.Examples[name] {
+			links = links && isLinkValue(v)
+		}
+	}
+
+	candidates := map[string]*inferCandidate{}
+	var order []string
+	add := func(c *inferCandidate) {
+		if _, found := candidates[c.selector]; !found {
+			candidates[c.selector] = c
+			order = append(order, c.selector)
+		}
+	}
+	for _, p := range pages {
+		expected := p.Examples[name]
+		if len(expected) == 0 {
+			continue
+		}
+		field.Pages++
+		for _, n := range exampleNodes(p, expected, links) {
+			for _, c := range nodeCandidates(n, links) {
+				add(c)
+			}
+		}
+	}
+
+	var best *inferCandidate
+	var bestScore inferScore
+	for _, key := range order {
+		c := candidates[key]
+		s := scoreCandidate(c, name, pages, links)
+		if best == nil || betterCandidate(s, bestScore, c, best) {
+			best, bestScore = c, s
+		}
+	}
+	if best == nil {
+		for _, p := range pages {
+			field.Missing = append(field.Missing, p.Examples[name]...)
+		}
+		return field
+	}
+	remapKey := "text"
+	if links {
+		remapKey = "link"
+	}
+	last := best.searches[len(best.searches)-1].Order
+	field.Searches = append(best.searches, &Search{Type: TypeAttribute, Tag: remapKey, InternalTagName: name, Order: last, OnlyRemap: true})
+	field.Selector = best.selector
+	field.Matched = bestScore.matched
+	field.Recall = bestScore.recall
+	field.Precision = bestScore.precision
+	field.Missing = bestScore.missing
+	return field
+}
+
+// betterCandidate prefers finding more examples, then fewer values that are not examples, then selectors using an attribute
+// since a bare tag breaks as soon as another page has one more of it, then shorter search lists
+func betterCandidate(s, best inferScore, c, bestCandidate *inferCandidate) bool {
+	if s.recall != best.recall {
+		return s.recall > best.recall
+	}
+	if s.precision != best.precision {
+		return s.precision > best.precision
+	}
+	if c.attributes() != bestCandidate.attributes() {
+		return c.attributes()
+	}
+	return len(c.searches) < len(bestCandidate.searches)
+}
+
+func (c *inferCandidate) attributes() bool {
+	for _, s := range c.searches {
+		if s.Type == TypeAttribute {
+			return true
+		}
+	}
+	return false
+}
+
+// scoreCandidate runs the candidate over every page with examples for the field
+func scoreCandidate(c *inferCandidate, name string, pages []*InferencePage, links bool) inferScore {
+	score := inferScore{}
+	counted := 0
+	parser := &Parser{SearchList: c.searches}
+	for _, p := range pages {
+		expected := p.Examples[name]
+		if len(expected) == 0 {
+			continue
+		}
+		counted++
+		u, _ := url.Parse(p.URL)
+		nodes, _ := parser.parse(p.Page, u)
+		want := map[string]struct{}{}
+		for _, e := range expected {
+			want[normalizeExample(e, u, links)] = struct{}{}
+		}
+		found := map[string]struct{}{}
+		hits := 0
+		for _, n := range nodes {
+			v := nodeValue(n, u, links)
+			if _, ok := want[v]; ok {
+				hits++
+				found[v] = struct{}{}
+			}
+		}
+		for _, e := range expected {
+			if _, ok := found[normalizeExample(e, u, links)]; !ok {
+				score.missing = append(score.missing, e)
+			}
+		}
+		if len(found) == len(want) {
+			score.matched++
+		}
+		score.recall += float64(len(found)) / float64(len(want))
+		if len(nodes) > 0 {
+			score.precision += float64(hits) / float64(len(nodes))
+		}
+	}
+	if counted > 0 {
+		score.recall /= float64(counted)
+		score.precision /= float64(counted)
+	}
+	return score
+}
+
+// exampleNodes returns the nodes of a page holding one of the expected values
+func exampleNodes(p *InferencePage, expected []string, links bool) []*v2.HtmlData {
+	u, _ := url.Parse(p.URL)
+	want := map[string]struct{}{}
+	for _, e := range expected {
+		want[normalizeExample(e, u, links)] = struct{}{}
+	}
+	var output []*v2.HtmlData
+	for _, n := range p.Page.GetTags(nil) {
+		if _, ok := want[nodeValue(n, u, links)]; ok {
+			output = append(output, n)
+			if len(output) == inferNodes*len(expected) {
+				break
+			}
+		}
+	}
+	return output
+}
+
+// nodeCandidates generalizes a node into selectors: its tag, its tag with one attribute pattern
+// and both of those scoped by an ancestor with an id or class
+func nodeCandidates(n *v2.HtmlData, links bool) []*inferCandidate {
+	tag := strings.ToLower(n.Tag)
+	own := []*inferCandidate{{
+		searches: []*Search{{Type: TypeTag, Tag: tag}},
+		selector: tag,
+	}}
+	patterns := attributePatterns(n, inferAttribute)
+	if links {
+		patterns = append(patterns, linkPatterns(n)...)
+	}
+	for _, ap := range patterns {
+		own = append(own, &inferCandidate{
+			searches: []*Search{{Type: TypeTag, Tag: tag}, {Type: TypeAttribute, Tag: ap.key, TagValue: ap.value}},
+			selector: fmt.Sprintf("%s[%s~=%s]", tag, ap.key, ap.value),
+		})
+	}
+	output := append([]*inferCandidate{}, own...)
+	ancestor := n.Parent
+	for level := 0; ancestor != nil && level < inferAncestors; level++ {
+		aTag := strings.ToLower(ancestor.Tag)
+		for _, ap := range attributePatterns(ancestor, []string{"id", "class"}) {
+			scope := &Search{Type: TypeAttribute, Tag: ap.key, TagValue: ap.value, ForwardData: true}
+			for _, o := range own {
+				searches := []*Search{scope}
+				for _, s := range o.searches {
+					c := *s
+					c.Order = 1
+					searches = append(searches, &c)
+				}
+				output = append(output, &inferCandidate{
+					searches: searches,
+					selector: fmt.Sprintf("%s[%s~=%s] > %s", aTag, ap.key, ap.value, o.selector),
+				})
+			}
+		}
+		ancestor = ancestor.Parent
+	}
+	return output
+}
+
+type attributePattern struct {
+	key, value string
+}
+
+// attributePatterns returns anchored regex patterns for the attributes of a node, numbers are generalized
+// so post-12 also matches post-13, classes produce one pattern per class name
+func attributePatterns(n *v2.HtmlData, keys []string) []attributePattern {
+	var output []attributePattern
+	for _, key := range keys {
+		v := strings.TrimSpace(n.Attributes[key])
+		if v == "" {
+			continue
+		}
+		if key == "class" {
+			for _, class := range strings.Fields(v) {
+				output = append(output, attributePattern{key, `(^|\s)` + generalizeDigits(class) + `(\s|$)`})
+			}
+			continue
+		}
+		output = append(output, attributePattern{key, "^" + generalizeDigits(v) + "$"})
+	}
+	return output
+}
+
+// linkPatterns returns patterns for the link of a node, one for the whole path with numbers generalized
+// and one for its first directory so slugs are allowed to differ between links
+func linkPatterns(n *v2.HtmlData) []attributePattern {
+	var output []attributePattern
+	for _, key := range []string{"href", "src", "data-src"} {
+		v := n.Attributes[key]
+		if v == "" {
+			continue
+		}
+		u, err := url.Parse(v)
+		if err != nil || u.Path == "" {
+			continue
+		}
+		prefix := ""
+		if u.Host != "" {
+			prefix = `^(https?:)?//[^/]+`
+		} else if strings.HasPrefix(u.Path, "/") {
+			prefix = `^(https?://[^/]+)?`
+		} else {
+			prefix = "^"
+		}
+		output = append(output, attributePattern{key, prefix + generalizeDigits(u.Path)})
+		if segments := strings.Split(strings.Trim(u.Path, "/"), "/"); len(segments) > 1 {
+			output = append(output, attributePattern{key, prefix + "/" + generalizeDigits(segments[0]) + "/"})
+		}
+		break
+	}
+	return output
+}
+
+func generalizeDigits(s string) string {
+	return digitRun.ReplaceAllString(regexp.QuoteMeta(s), "[0-9]+")
+}
+
+func isLinkValue(v string) bool {
+	v = strings.TrimSpace(v)
+	return strings.HasPrefix(v, "http://") || strings.HasPrefix(v, "https://") || strings.HasPrefix(v, "/")
+}
+
+// nodeValue returns the value the parser would remap for a node, its link or its own text
+func nodeValue(n *v2.HtmlData, u *url.URL, links bool) string {
+	if links {
+		l, _ := n.FindLinks(fmt.Sprintf("%s://%s", u.Scheme, u.Host), []string{"href", "src", "data-src"})
+		return l
+	}
+	return normalizeText(n.TextData)
+}
+
+func normalizeExample(v string, u *url.URL, links bool) string {
+	if !links {
+		return normalizeText(v)
+	}
+	l, _ := (&v2.HtmlData{Attributes: map[string]string{"href": strings.TrimSpace(v)}}).FindLinks(fmt.Sprintf("%s://%s", u.Scheme, u.Host), []string{"href"})
+	return l
+}
+
+func normalizeText(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
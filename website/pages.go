@@ -0,0 +1,75 @@
+package website
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"net/url"
+	"strings"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+)
+
+// ErrStopPages can be returned from the function given to FollowPages to stop following pages
+var ErrStopPages = errors.New("stop following pages")
+
+// PageLoop follows the pages of a listing, Definition.FollowPages and the pagination of the analyzer both run it
+type PageLoop struct {
+	// MaxPages stops the loop after this many pages, 0 for no limit
+	MaxPages int
+	// Load requests a page, a nil page ends the loop
+	Load func(ctx context.Context, pageURL string) (*v2.HtmlData, error)
+	// Next returns the url of the page after page, empty on the last page
+	Next func(page *v2.HtmlData, pageURL string) string
+}
+
+// Run loads startURL and every next page, calling fn for each of them
+// it stops after MaxPages pages, when a url was already visited, when a page repeats the previous one, when ctx is done
+// or when fn returns an error, ErrStopPages stops without returning an error
+func (l *PageLoop) Run(ctx context.Context, startURL string, fn func(pageURL string, page *v2.HtmlData) error) error {
+	visited := map[string]struct{}{}
+	previous := ""
+	pageURL := startURL
+	for i := 0; pageURL != "" && (l.MaxPages <= 0 || i < l.MaxPages); i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		key := NormalizeURL(pageURL)
+		if _, found := visited[key]; found {
+			return nil
+		}
+		visited[key] = struct{}{}
+		page, err := l.Load(ctx, pageURL)
+		if err != nil {
+			return err
+		}
+		if page == nil {
+			return nil
+		}
+		hash := sha1.Sum([]byte(page.Text()))
+		if string(hash[:]) == previous {
+			return nil
+		}
+		previous = string(hash[:])
+		if err := fn(pageURL, page); err != nil {
+			if errors.Is(err, ErrStopPages) {
+				return nil
+			}
+			return err
+		}
+		pageURL = l.Next(page, pageURL)
+	}
+	return nil
+}
+
+// NormalizeURL drops the fragment, a trailing slash and the case of the host so the same page linked twice is found once
+func NormalizeURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	u.Fragment = ""
+	u.Host = strings.ToLower(u.Host)
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	return u.String()
+}
@@ -0,0 +1,307 @@
+package website
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+)
+
+// regexPattern starts host and path patterns written as regular expressions, ie "regex:^/series/\d+$"
+const regexPattern = "regex:"
+
+var patternCache sync.Map // glob or regex pattern -> *regexp.Regexp
+
+// MatchURL reports whether the parser handles pageURL, see URLs, Hosts and Paths
+func (wp *Parser) MatchURL(pageURL string) bool {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return false
+	}
+	_, ok := matchURL(wp.WebsiteURL, wp.URLs, wp.Hosts, wp.Paths, u)
+	return ok
+}
+
+// matchURL matches a page against url patterns, or host and path patterns, the host of websiteURL is the host pattern
+// when there are neither, the returned specificity is the length of the literal part of the patterns that matched
+func matchURL(websiteURL string, urls, hosts, paths []string, u *url.URL) (int, bool) {
+	best, found := 0, false
+	for _, pattern := range urls {
+		if s, ok := matchURLPattern(pattern, u); ok && (!found || s > best) {
+			best, found = s, true
+		}
+	}
+	if len(hosts) == 0 && len(urls) == 0 {
+		if w, err := url.Parse(websiteURL); err == nil && w.Host != "" {
+			hosts = []string{strings.TrimPrefix(strings.ToLower(w.Hostname()), "www.")}
+		}
+	}
+	if len(hosts) == 0 {
+		return best, found
+	}
+	hostScore, hostFound := 0, false
+	for _, pattern := range hosts {
+		if s, ok := matchHost(pattern, u.Hostname()); ok && (!hostFound || s > hostScore) {
+			hostScore, hostFound = s, true
+		}
+	}
+	if !hostFound {
+		return best, found
+	}
+	pathScore, pathFound := 0, len(paths) == 0
+	for _, pattern := range paths {
+		if s, ok := matchPath(pattern, u.Path); ok && (!pathFound || s > pathScore) {
+			pathScore, pathFound = s, true
+		}
+	}
+	if pathFound && (!found || hostScore+pathScore > best) {
+		best, found = hostScore+pathScore, true
+	}
+	return best, found
+}
+
+// matchURLPattern matches a url pattern like https://*.example.com/series/**, the scheme is optional
+func matchURLPattern(pattern string, u *url.URL) (int, bool) {
+	p, err := url.Parse(pattern)
+	if err != nil || p.Host == "" {
+		return 0, false
+	}
+	if p.Scheme != "" && !strings.EqualFold(p.Scheme, u.Scheme) {
+		return 0, false
+	}
+	hostScore, ok := matchHost(p.Hostname(), u.Hostname())
+	if !ok {
+		return 0, false
+	}
+	pathScore, ok := matchPath(p.Path, u.Path)
+	return hostScore + pathScore, ok
+}
+
+// matchHost matches a host pattern, "example.com" matches the host and its www. form, "*.example.com" any sub domain,
+// "*" every host and patterns starting with regex: are regular expressions
+func matchHost(pattern, host string) (int, bool) {
+	host = strings.ToLower(host)
+	if strings.HasPrefix(pattern, regexPattern) {
+		re, err := patternRegexp(pattern)
+		return len(pattern), err == nil && re.MatchString(host)
+	}
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	score := len(strings.NewReplacer("*", "", "?", "").Replace(pattern))
+	if pattern == host || "www."+pattern == host {
+		return score, true
+	}
+	ok, _ := path.Match(pattern, host)
+	return score, ok
+}
+
+// matchPath matches a path pattern, * matches inside a path segment, ** across segments and an empty pattern or / any path
+// patterns starting with regex: are regular expressions
+func matchPath(pattern, p string) (int, bool) {
+	if pattern == "" || pattern == "/" {
+		return 0, true
+	}
+	if p == "" {
+		p = "/"
+	}
+	re, err := patternRegexp(pattern)
+	if err != nil {
+		return 0, false
+	}
+	if strings.HasPrefix(pattern, regexPattern) {
+		return len(pattern), re.MatchString(p)
+	}
+	return len(strings.NewReplacer("*", "", "?", "").Replace(pattern)), re.MatchString(p)
+}
+
+// patternRegexp compiles a regex: pattern or a path glob
+func patternRegexp(pattern string) (*regexp.Regexp, error) {
+	if re, found := patternCache.Load(pattern); found {
+		return re.(*regexp.Regexp), nil
+	}
+	expr := strings.TrimPrefix(pattern, regexPattern)
+	if !strings.HasPrefix(pattern, regexPattern) {
+		expr = globExpr(pattern)
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	patternCache.Store(pattern, re)
+	return re, nil
+}
+
+// globExpr turns a path glob into a regular expression, a trailing /** also matches the path without it
+func globExpr(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			b.WriteString("(?:/.*)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// validatePatterns returns a message for every host, path and url pattern that can not be used
+func validatePatterns(urls, hosts, paths []string) []string {
+	var problems []string
+	check := func(kind, pattern string) {
+		if strings.TrimSpace(pattern) == "" {
+			problems = append(problems, fmt.Sprintf("empty %s pattern", kind))
+			return
+		}
+		if strings.HasPrefix(pattern, regexPattern) {
+			if _, err := patternRegexp(pattern); err != nil {
+				problems = append(problems, fmt.Sprintf("%s pattern %q: %v", kind, pattern, err))
+			}
+			return
+		}
+		if kind == "host" {
+			if _, err := path.Match(pattern, ""); err != nil {
+				problems = append(problems, fmt.Sprintf("host pattern %q: %v", pattern, err))
+			}
+		}
+	}
+	for _, h := range hosts {
+		check("host", h)
+	}
+	for _, p := range paths {
+		check("path", p)
+	}
+	for _, pattern := range urls {
+		if u, err := url.Parse(pattern); err != nil || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("%q is not a url pattern like https://example.com/path/*", pattern))
+		} else if _, err := path.Match(u.Hostname(), ""); err != nil {
+			problems = append(problems, fmt.Sprintf("url pattern %q: %v", pattern, err))
+		}
+	}
+	return problems
+}
+
+// ParserRegistry picks the parser of a page among the parsers of every supported site
+type ParserRegistry struct {
+	mu      sync.RWMutex
+	parsers []*Parser
+}
+
+// NewParserRegistry creates a registry holding parsers
+func NewParserRegistry(parsers ...*Parser) (*ParserRegistry, error) {
+	r := &ParserRegistry{}
+	if err := r.Register(parsers...); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// LoadRegistry reads a directory of definitions into a registry, see LoadParsers
+func LoadRegistry(dir string) (*ParserRegistry, error) {
+	parsers, err := LoadParsers(dir)
+	if err != nil {
+		return nil, err
+	}
+	return NewParserRegistry(parsers...)
+}
+
+// Register adds parsers to the registry, a parser is validated first and parsers with the same name are rejected
+func (r *ParserRegistry) Register(parsers ...*Parser) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range parsers {
+		if p == nil {
+			return fmt.Errorf("parser is nil")
+		}
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("parser %s: %v", p.Name, err)
+		}
+		for _, other := range r.parsers {
+			if other.Name == p.Name {
+				return fmt.Errorf("parser %s is already registered", p.Name)
+			}
+		}
+		r.parsers = append(r.parsers, p)
+	}
+	return nil
+}
+
+// Parsers returns the registered parsers in registration order
+func (r *ParserRegistry) Parsers() []*Parser {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]*Parser{}, r.parsers...)
+}
+
+// Lookup returns the parser handling pageURL, the parser with the highest Priority wins, then the one whose
+// patterns matched more literal text and then the first registered
+func (r *ParserRegistry) Lookup(pageURL string) (*Parser, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	type candidate struct {
+		p           *Parser
+		specificity int
+	}
+	var candidates []candidate
+	r.mu.RLock()
+	for _, p := range r.parsers {
+		if s, ok := matchURL(p.WebsiteURL, p.URLs, p.Hosts, p.Paths, u); ok {
+			candidates = append(candidates, candidate{p, s})
+		}
+	}
+	r.mu.RUnlock()
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no parser handles %s", pageURL)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].p.Priority != candidates[j].p.Priority {
+			return candidates[i].p.Priority > candidates[j].p.Priority
+		}
+		return candidates[i].specificity > candidates[j].specificity
+	})
+	return candidates[0].p, nil
+}
+
+// Parse requests pageURL and runs the parser handling it, see Parser.Parse
+func (r *ParserRegistry) Parse(sourceReq *v2.HTMLSourceRequest, pageURL string) ([]*v2.HtmlData, []map[string]string, error) {
+	p, err := r.Lookup(pageURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p.Parse(sourceReq, pageURL)
+}
+
+// ParseDocument runs the parser handling pageURL over a page that is already loaded, see Parser.ParseDocument
+func (r *ParserRegistry) ParseDocument(doc *v2.HtmlData, pageURL string) ([]*v2.HtmlData, []map[string]string, error) {
+	p, err := r.Lookup(pageURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p.ParseDocument(doc, pageURL)
+}
+
+// ParseReader reads html from rd and runs the parser handling pageURL over it, see Parser.ParseReader
+func (r *ParserRegistry) ParseReader(rd io.Reader, pageURL string) ([]*v2.HtmlData, []map[string]string, error) {
+	p, err := r.Lookup(pageURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p.ParseReader(rd, pageURL)
+}
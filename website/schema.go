@@ -0,0 +1,305 @@
+package website
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+)
+
+// FieldType is the type a schema field is converted to
+type FieldType string
+
+const (
+	FieldString FieldType = "string"
+	FieldInt    FieldType = "int"
+	FieldFloat  FieldType = "float"
+	FieldBool   FieldType = "bool"
+	FieldTime   FieldType = "time"
+	// FieldURL resolves the value against the url of the page
+	FieldURL FieldType = "url"
+	// FieldObject is a nested object built from Fields
+	FieldObject FieldType = "object"
+	// FieldList collects every match of the selector, Item describes each of them
+	FieldList FieldType = "list"
+)
+
+// Schema describes the records a Parser outputs, one record is built for every root element
+type Schema struct {
+	// Selector is the css selector of the root elements, when empty the results of the search list are the roots
+	// and the page itself when the search list is empty too
+	Selector string         `json:"selector,omitempty" yaml:"selector"`
+	Fields   []*SchemaField `json:"fields" yaml:"fields"`
+}
+
+// SchemaField is a value of a record, read from the first element matching Selector inside the parent element
+//
+//	{name: title, selector: h1}
+//	{name: chapters, type: list, selector: ul.chapters > li, item: {type: object, fields: [{name: url, selector: a, type: url}]}}
+type SchemaField struct {
+	Name string `json:"name" yaml:"name"`
+	// Selector is a css selector relative to the parent element, the parent element itself when empty
+	Selector string `json:"selector,omitempty" yaml:"selector"`
+	// Attr is the attribute holding the value, text is the readable text of the element, own_text only its own text
+	// and inner_text keeps line breaks, the default is text or the first link attribute for urls
+	Attr string `json:"attr,omitempty" yaml:"attr"`
+	// Type defaults to string, or object when Fields is set
+	Type FieldType `json:"type,omitempty" yaml:"type"`
+	// Layout is the time.Parse layout of time fields, common layouts are tried when empty
+	Layout string `json:"layout,omitempty" yaml:"layout"`
+	// Transform is a pipeline applied to the value before it is converted to Type, see ParseTransforms
+	Transform string `json:"transform,omitempty" yaml:"transform"`
+	// Fields are the fields of an object
+	Fields []*SchemaField `json:"fields,omitempty" yaml:"fields"`
+	// Item is the type of the elements of a list, a string of the text when nil
+	Item *SchemaField `json:"item,omitempty" yaml:"item"`
+
+	selector *v2.Selector
+	pipeline []*Transform
+	compiled bool
+}
+
+var (
+	// schemaTimeLayouts are tried in order when a time field has no layout
+	schemaTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02", "2006/01/02",
+		"01/02/2006", "02.01.2006", "January 2, 2006", "Jan 2, 2006", "2 January 2006", "2 Jan 2006", time.RFC1123,
+		time.RFC1123Z}
+	linkAttributes = []string{"href", "src", "data-src", "data-href", "content", "value"}
+)
+
+// Compile checks the schema and compiles its selectors, Extract calls it so it is only needed to report errors early
+func (s *Schema) Compile() error {
+	if s.Selector != "" {
+		if _, err := v2.CompileSelector(s.Selector); err != nil {
+			return err
+		}
+	}
+	if len(s.Fields) == 0 {
+		return fmt.Errorf("schema has no fields")
+	}
+	return compileFields(s.Fields, "")
+}
+
+func compileFields(fields []*SchemaField, parent string) error {
+	names := map[string]struct{}{}
+	for i, f := range fields {
+		if f == nil {
+			return fmt.Errorf("field %s[%d] is empty", parent, i)
+		}
+		path := f.Name
+		if parent != "" {
+			path = parent + "." + f.Name
+		}
+		if f.Name == "" {
+			return fmt.Errorf("field %s[%d] has no name", parent, i)
+		}
+		if _, found := names[f.Name]; found {
+			return fmt.Errorf("field %s is defined twice", path)
+		}
+		names[f.Name] = struct{}{}
+		if err := f.compile(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *SchemaField) compile(path string) error {
+	if !f.compiled {
+		if f.Selector != "" {
+			s, err := v2.CompileSelector(f.Selector)
+			if err != nil {
+				return fmt.Errorf("field %s: %v", path, err)
+			}
+			f.selector = s
+		}
+		pipeline, err := ParseTransforms(f.Transform)
+		if err != nil {
+			return fmt.Errorf("field %s: %v", path, err)
+		}
+		f.pipeline, f.compiled = pipeline, true
+	}
+	switch f.fieldType() {
+	case FieldString, FieldInt, FieldFloat, FieldBool, FieldTime, FieldURL:
+		if len(f.Fields) > 0 || f.Item != nil {
+			return fmt.Errorf("field %s: a %s field can not have fields or an item", path, f.fieldType())
+		}
+	case FieldObject:
+		if len(f.Fields) == 0 {
+			return fmt.Errorf("field %s: an object needs fields", path)
+		}
+		return compileFields(f.Fields, path)
+	case FieldList:
+		if f.Item != nil {
+			if f.Item.fieldType() == FieldList {
+				return fmt.Errorf("field %s: lists of lists are not supported, use a list of objects", path)
+			}
+			return f.Item.compile(path + "[]")
+		}
+	default:
+		return fmt.Errorf("field %s: unknown type %q", path, f.Type)
+	}
+	return nil
+}
+
+func (f *SchemaField) fieldType() FieldType {
+	switch {
+	case f.Type != "":
+		return f.Type
+	case len(f.Fields) > 0:
+		return FieldObject
+	}
+	return FieldString
+}
+
+// Extract runs the parser over a loaded page and builds a record of the schema for every root element
+func (wp *Parser) Extract(page *v2.HtmlData, pageURL string) ([]map[string]interface{}, error) {
+	if wp.Schema == nil {
+		return nil, fmt.Errorf("parser %s has no schema", wp.Name)
+	}
+	if err := wp.Schema.Compile(); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	roots, err := wp.roots(page, u)
+	if err != nil {
+		return nil, err
+	}
+	var output []map[string]interface{}
+	for _, r := range roots {
+		output = append(output, objectValue(r, wp.Schema.Fields, u))
+	}
+	return output, nil
+}
+
+// roots returns the elements records are built from, the elements of Schema.Selector when set,
+// the results of the search list or else the page itself
+func (wp *Parser) roots(page *v2.HtmlData, u *url.URL) ([]*v2.HtmlData, error) {
+	switch {
+	case wp.Schema != nil && wp.Schema.Selector != "":
+		return page.Select(wp.Schema.Selector)
+	case len(wp.SearchList) > 0:
+		roots, _ := wp.parse(page, u)
+		return roots, nil
+	}
+	return []*v2.HtmlData{page}, nil
+}
+
+// ExtractJSON is Extract encoded as a json array
+func (wp *Parser) ExtractJSON(page *v2.HtmlData, pageURL string) ([]byte, error) {
+	records, err := wp.Extract(page, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	if records == nil {
+		records = []map[string]interface{}{}
+	}
+	return json.Marshal(records)
+}
+
+func objectValue(n *v2.HtmlData, fields []*SchemaField, u *url.URL) map[string]interface{} {
+	output := map[string]interface{}{}
+	for _, f := range fields {
+		output[f.Name] = f.value(n, u)
+	}
+	return output
+}
+
+// value reads the field inside parent, missing values are nil and lists are never nil
+func (f *SchemaField) value(parent *v2.HtmlData, u *url.URL) interface{} {
+	if f.fieldType() == FieldList {
+		matches := []*v2.HtmlData{parent}
+		if f.selector != nil {
+			matches = parent.SelectCompiled(f.selector)
+		}
+		output := []interface{}{}
+		for _, m := range matches {
+			var v interface{}
+			if f.Item == nil {
+				v = convertValue(FieldString, f.read(m, u), "")
+			} else {
+				v = f.Item.value(m, u)
+			}
+			if v != nil {
+				output = append(output, v)
+			}
+		}
+		return output
+	}
+	n := parent
+	if f.selector != nil {
+		found := parent.SelectCompiled(f.selector)
+		if len(found) == 0 {
+			return nil
+		}
+		n = found[0]
+	}
+	if f.fieldType() == FieldObject {
+		return objectValue(n, f.Fields, u)
+	}
+	return convertValue(f.fieldType(), f.read(n, u), f.Layout)
+}
+
+// read returns the value of the element after the pipeline, urls are resolved against the page
+// a value the pipeline fails on is missing
+func (f *SchemaField) read(n *v2.HtmlData, u *url.URL) string {
+	v, err := ApplyTransforms(elementValue(n, f.Attr, u, f.fieldType() == FieldURL), f.pipeline, u)
+	if err != nil {
+		return ""
+	}
+	return v
+}
+
+// convertValue turns the text of an element into the type, values that do not convert are nil
+func convertValue(t FieldType, v, layout string) interface{} {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return nil
+	}
+	switch t {
+	case FieldInt:
+		// numbers are read like the number transform reads them and numbers with decimals are not integers
+		n, ok := integerText(v)
+		if !ok {
+			return nil
+		}
+		i, err := strconv.ParseInt(n, 10, 64)
+		if err != nil {
+			return nil
+		}
+		return i
+	case FieldFloat:
+		f, ok := numberValue(v)
+		if !ok {
+			return nil
+		}
+		return f
+	case FieldBool:
+		switch strings.ToLower(v) {
+		case "true", "yes", "y", "1", "on", "checked", "selected":
+			return true
+		case "false", "no", "n", "0", "off":
+			return false
+		}
+		return nil
+	case FieldTime:
+		layouts := schemaTimeLayouts
+		if layout != "" {
+			layouts = []string{layout}
+		}
+		for _, l := range layouts {
+			if t, err := time.Parse(l, v); err == nil {
+				return t
+			}
+		}
+		return nil
+	}
+	return v
+}
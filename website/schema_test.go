@@ -0,0 +1,30 @@
+package website
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConvertNumbers(t *testing.T) {
+	for _, c := range []struct {
+		t    FieldType
+		in   string
+		want interface{}
+	}{
+		{FieldInt, "12", int64(12)},
+		{FieldInt, "1,234,567 views", int64(1234567)},
+		{FieldInt, "12.0", int64(12)},
+		{FieldInt, "12.9", nil},
+		{FieldInt, "1.234", nil},
+		{FieldInt, "-7", int64(-7)},
+		{FieldInt, "none", nil},
+		{FieldFloat, "1.234,5", 1234.5},
+		{FieldFloat, "4,5", 4.5},
+		{FieldFloat, "1,234", 1234.0},
+		{FieldFloat, ".5", 0.5},
+	} {
+		if got := convertValue(c.t, c.in, ""); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("convertValue(%s, %q) = %#v, want %#v", c.t, c.in, got, c.want)
+		}
+	}
+}
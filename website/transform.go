@@ -0,0 +1,447 @@
+package website
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TransformFunc changes a value, args are the arguments written after the name of the transform
+type TransformFunc func(value string, args []string, ctx *TransformContext) (string, error)
+
+// TransformContext is what a transform knows about the page the value comes from
+type TransformContext struct {
+	PageURL *url.URL
+}
+
+// Transform is a step of a pipeline, written as name or name(arg, 'quoted arg') in a pipeline spec
+type Transform struct {
+	Name string   `json:"name" yaml:"name"`
+	Args []string `json:"args,omitempty" yaml:"args"`
+}
+
+func (t *Transform) String() string {
+	if len(t.Args) == 0 {
+		return t.Name
+	}
+	var args []string
+	for _, a := range t.Args {
+		args = append(args, "'"+strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(a)+"'")
+	}
+	return fmt.Sprintf("%s(%s)", t.Name, strings.Join(args, ", "))
+}
+
+var (
+	transformsMu sync.RWMutex
+	transforms   = map[string]TransformFunc{
+		"trim":          trimTransform,
+		"collapse":      collapseTransform,
+		"lower":         lowerTransform,
+		"upper":         upperTransform,
+		"regex_extract": regexExtract,
+		"regex_replace": regexReplace,
+		"replace":       replaceTransform,
+		"split":         splitTransform,
+		"join":          joinTransform,
+		"number":        numberTransform,
+		"float":         numberTransform,
+		"int":           intTransform,
+		"date":          dateTransform,
+		"url":           urlTransform,
+		"strip_html":    stripHTML,
+		"default":       defaultTransform,
+		"map":           mapTransform,
+	}
+	htmlTag      = regexp.MustCompile(`(?s)<!--.*?-->|<[a-zA-Z/!][^>]*>`)
+	regexCache   sync.Map // pattern -> *regexp.Regexp
+	localeNumber = regexp.MustCompile(`[-+]?(?:\d+(?:[.,' \x{00a0}\x{202f}]\d+)*|[.,]\d+)`)
+)
+
+// RegisterTransform adds a transform usable in every pipeline, a transform with the same name is replaced
+func RegisterTransform(name string, fn TransformFunc) {
+	transformsMu.Lock()
+	defer transformsMu.Unlock()
+	transforms[name] = fn
+}
+
+func lookupTransform(name string) (TransformFunc, bool) {
+	transformsMu.RLock()
+	defer transformsMu.RUnlock()
+	fn, found := transforms[name]
+	return fn, found
+}
+
+// ParseTransforms reads a pipeline spec like "trim, regex_extract('(\d+)'), default(0)", transforms are separated by commas
+// and arguments are quoted with ' or " when they hold commas, parentheses or spaces, inside quotes only \' \" and \\ are escapes
+// so regular expressions can be written as they are
+func ParseTransforms(spec string) ([]*Transform, error) {
+	var pipeline []*Transform
+	for _, part := range splitArgs(spec) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		t := &Transform{Name: part}
+		if i := strings.Index(part, "("); i >= 0 {
+			if !strings.HasSuffix(part, ")") {
+				return nil, fmt.Errorf("transform %q is missing a )", part)
+			}
+			t.Name = strings.TrimSpace(part[:i])
+			for _, arg := range splitArgs(part[i+1 : len(part)-1]) {
+				t.Args = append(t.Args, unquoteArg(strings.TrimSpace(arg)))
+			}
+		}
+		if _, found := lookupTransform(t.Name); !found {
+			return nil, fmt.Errorf("unknown transform %q", t.Name)
+		}
+		pipeline = append(pipeline, t)
+	}
+	return pipeline, nil
+}
+
+// splitArgs splits on the commas that are not inside quotes or parentheses
+func splitArgs(s string) []string {
+	var parts []string
+	var quote byte
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && i+1 < len(s) && (s[i+1] == quote || s[i+1] == '\\') {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	if strings.TrimSpace(s[start:]) != "" || len(parts) > 0 {
+		parts = append(parts, s[start:])
+	}
+	return parts
+}
+
+func unquoteArg(arg string) string {
+	if len(arg) < 2 || (arg[0] != '\'' && arg[0] != '"') || arg[len(arg)-1] != arg[0] {
+		return arg
+	}
+	quote := arg[0]
+	arg = arg[1 : len(arg)-1]
+	var b strings.Builder
+	for i := 0; i < len(arg); i++ {
+		if arg[i] == '\\' && i+1 < len(arg) && (arg[i+1] == quote || arg[i+1] == '\\') {
+			i++
+		}
+		b.WriteByte(arg[i])
+	}
+	return b.String()
+}
+
+// ApplyTransforms runs a pipeline over a value, u is the url of the page
+func ApplyTransforms(value string, pipeline []*Transform, u *url.URL) (string, error) {
+	ctx := &TransformContext{PageURL: u}
+	for _, t := range pipeline {
+		fn, found := lookupTransform(t.Name)
+		if !found {
+			return "", fmt.Errorf("unknown transform %q", t.Name)
+		}
+		var err error
+		if value, err = fn(value, t.Args, ctx); err != nil {
+			return "", fmt.Errorf("transform %s: %v", t.Name, err)
+		}
+	}
+	return value, nil
+}
+
+func arg(args []string, i int, fallback string) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return fallback
+}
+
+func compiledRegex(pattern string) (*regexp.Regexp, error) {
+	if re, found := regexCache.Load(pattern); found {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexCache.Store(pattern, re)
+	return re, nil
+}
+
+// trim removes spaces, or the characters of the first argument, from both ends
+func trimTransform(v string, args []string, _ *TransformContext) (string, error) {
+	if len(args) > 0 {
+		return strings.Trim(v, args[0]), nil
+	}
+	return strings.TrimSpace(v), nil
+}
+
+// collapse replaces every run of spaces and line breaks with a single space
+func collapseTransform(v string, _ []string, _ *TransformContext) (string, error) {
+	return strings.Join(strings.Fields(v), " "), nil
+}
+
+func lowerTransform(v string, _ []string, _ *TransformContext) (string, error) {
+	return strings.ToLower(v), nil
+}
+
+func upperTransform(v string, _ []string, _ *TransformContext) (string, error) {
+	return strings.ToUpper(v), nil
+}
+
+// regex_extract(pattern, group) returns the group of the first match, group 1 when the pattern has groups and the whole match
+// otherwise, the value becomes empty when nothing matches
+func regexExtract(v string, args []string, _ *TransformContext) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("a pattern is required")
+	}
+	re, err := compiledRegex(args[0])
+	if err != nil {
+		return "", err
+	}
+	group := 0
+	if re.NumSubexp() > 0 {
+		group = 1
+	}
+	if len(args) > 1 {
+		if group, err = strconv.Atoi(args[1]); err != nil || group > re.NumSubexp() || group < 0 {
+			return "", fmt.Errorf("invalid group %q", args[1])
+		}
+	}
+	m := re.FindStringSubmatch(v)
+	if m == nil {
+		return "", nil
+	}
+	return m[group], nil
+}
+
+// regex_replace(pattern, replacement) replaces every match, $1 in the replacement is the first group
+func regexReplace(v string, args []string, _ *TransformContext) (string, error) {
+	if len(args) != 2 {
+		return "", fmt.Errorf("a pattern and a replacement are required")
+	}
+	re, err := compiledRegex(args[0])
+	if err != nil {
+		return "", err
+	}
+	return re.ReplaceAllString(v, args[1]), nil
+}
+
+// replace(old, new) replaces every old with new
+func replaceTransform(v string, args []string, _ *TransformContext) (string, error) {
+	if len(args) != 2 {
+		return "", fmt.Errorf("old and new are required")
+	}
+	return strings.ReplaceAll(v, args[0], args[1]), nil
+}
+
+// split(separator, index) returns the part at index, negative indexes count from the end, without an index every part
+// is kept on its own line so join can put them back together with another separator
+func splitTransform(v string, args []string, _ *TransformContext) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("a separator is required")
+	}
+	var parts []string
+	for _, p := range strings.Split(v, args[0]) {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if len(args) < 2 {
+		return strings.Join(parts, "\n"), nil
+	}
+	i, err := strconv.Atoi(args[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid index %q", args[1])
+	}
+	if i < 0 {
+		i += len(parts)
+	}
+	if i < 0 || i >= len(parts) {
+		return "", nil
+	}
+	return parts[i], nil
+}
+
+// join(separator) joins the lines made by split, the separator defaults to ", "
+func joinTransform(v string, args []string, _ *TransformContext) (string, error) {
+	var parts []string
+	for _, p := range strings.Split(v, "\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, arg(args, 0, ", ")), nil
+}
+
+// number(locale) keeps the first number of the value written as 1234.5, the locale tells which separator is the decimal one:
+// en uses 1,234.5, de, fr, es, it, pt, ru and most of europe use 1.234,5, without a locale it is guessed from the last separator
+func numberTransform(v string, args []string, _ *TransformContext) (string, error) {
+	m := strings.TrimSpace(localeNumber.FindString(v))
+	if m == "" {
+		return "", nil
+	}
+	decimal := byte('.')
+	switch locale := strings.ToLower(arg(args, 0, "")); {
+	case locale == "":
+		decimal = guessDecimal(m)
+	case strings.HasPrefix(locale, "en"), strings.HasPrefix(locale, "ja"), strings.HasPrefix(locale, "zh"),
+		strings.HasPrefix(locale, "ko"):
+	default:
+		decimal = ','
+	}
+	var b strings.Builder
+	for i := 0; i < len(m); i++ {
+		switch c := m[i]; {
+		case c >= '0' && c <= '9', c == '-' && b.Len() == 0:
+			b.WriteByte(c)
+		case c == decimal:
+			b.WriteByte('.')
+		}
+	}
+	out := strings.TrimSuffix(b.String(), ".")
+	if _, err := strconv.ParseFloat(out, 64); err != nil {
+		return "", fmt.Errorf("%q is not a number", m)
+	}
+	return out, nil
+}
+
+// guessDecimal picks the decimal separator of a number without a locale, a separator found more than once or a single
+// comma followed by three digits separates thousands ("1,234,567", "1,234"), otherwise the last separator is the decimal
+// one ("1.234,5", "4,5", "1.234")
+func guessDecimal(m string) byte {
+	last := strings.LastIndexAny(m, ".,")
+	if last < 0 {
+		return '.'
+	}
+	sep := m[last]
+	other := byte('.')
+	if sep == '.' {
+		other = ','
+	}
+	digits := len(strings.TrimSpace(m[last+1:]))
+	if strings.Count(m, string(sep)) > 1 || sep == ',' && digits == 3 && !strings.ContainsRune(m, '.') {
+		return other
+	}
+	return sep
+}
+
+// numberValue reads a number of a schema or a struct field the way the number transform does, see guessDecimal
+func numberValue(v string) (float64, bool) {
+	n, err := numberTransform(v, nil, nil)
+	if err != nil || n == "" {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(n, 64)
+	return f, err == nil
+}
+
+// integerText is the number of v when it is an integer, "12.0" is 12 but "12.9" is not an integer
+func integerText(v string) (string, bool) {
+	n, err := numberTransform(v, nil, nil)
+	if err != nil || n == "" {
+		return "", false
+	}
+	if i := strings.IndexByte(n, '.'); i >= 0 {
+		if strings.Trim(n[i+1:], "0") != "" {
+			return "", false
+		}
+		n = n[:i]
+	}
+	return n, n != "" && n != "-"
+}
+
+// int is number without the decimals
+func intTransform(v string, args []string, ctx *TransformContext) (string, error) {
+	n, err := numberTransform(v, args, ctx)
+	if i := strings.Index(n, "."); i >= 0 {
+		n = n[:i]
+	}
+	return n, err
+}
+
+// date(layout, ...) parses the value with the first matching layout and writes it as RFC 3339, common layouts are tried
+// when there are none
+func dateTransform(v string, args []string, _ *TransformContext) (string, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return "", nil
+	}
+	layouts := args
+	if len(layouts) == 0 {
+		layouts = schemaTimeLayouts
+	}
+	for _, l := range layouts {
+		if t, err := time.Parse(l, v); err == nil {
+			return t.Format(time.RFC3339), nil
+		}
+	}
+	return "", fmt.Errorf("%q does not match any layout", v)
+}
+
+// url resolves the value against the url of the page
+func urlTransform(v string, _ []string, ctx *TransformContext) (string, error) {
+	v = strings.TrimSpace(v)
+	if v == "" || ctx.PageURL == nil {
+		return v, nil
+	}
+	resolved, err := ctx.PageURL.Parse(v)
+	if err != nil {
+		return "", err
+	}
+	return resolved.String(), nil
+}
+
+// strip_html removes the tags and comments of html written in a value and decodes its entities
+func stripHTML(v string, _ []string, _ *TransformContext) (string, error) {
+	return strings.Join(strings.Fields(html.UnescapeString(htmlTag.ReplaceAllString(v, " "))), " "), nil
+}
+
+// default(value) is used when the value is empty
+func defaultTransform(v string, args []string, _ *TransformContext) (string, error) {
+	if strings.TrimSpace(v) == "" {
+		return arg(args, 0, ""), nil
+	}
+	return v, nil
+}
+
+// map(key=value, ...) replaces a value found in the list, keys are compared case insensitive and * matches any other value
+func mapTransform(v string, args []string, _ *TransformContext) (string, error) {
+	fallback, hasFallback := "", false
+	for _, a := range args {
+		i := strings.Index(a, "=")
+		if i < 0 {
+			return "", fmt.Errorf("%q is not written as key=value", a)
+		}
+		key, value := strings.TrimSpace(a[:i]), strings.TrimSpace(a[i+1:])
+		if key == "*" {
+			fallback, hasFallback = value, true
+			continue
+		}
+		if strings.EqualFold(key, strings.TrimSpace(v)) {
+			return value, nil
+		}
+	}
+	if hasFallback {
+		return fallback, nil
+	}
+	return v, nil
+}
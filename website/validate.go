@@ -0,0 +1,179 @@
+package website
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// SearchError is a problem with a Parser, Search is nil when the problem is not about a single search
+type SearchError struct {
+	// Index is the position of the search in the SearchList, -1 when Search is nil
+	Index   int
+	Search  *Search
+	Message string
+}
+
+func (e *SearchError) Error() string {
+	if e.Search == nil {
+		return e.Message
+	}
+	s := e.Search
+	return fmt.Sprintf("search %d (%s %q order %d): %s", e.Index, s.Type, s.Tag, s.Order, e.Message)
+}
+
+// ValidationErrors are all the problems found by Parser.Validate
+type ValidationErrors []*SearchError
+
+func (e ValidationErrors) Error() string {
+	var lines []string
+	for _, err := range e {
+		lines = append(lines, err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Validate checks the search list before it runs, a misconfigured search would otherwise only produce empty output
+// every problem is returned at once as ValidationErrors
+func (wp *Parser) Validate() error {
+	var errs ValidationErrors
+	fail := func(i int, message string, args ...interface{}) {
+		e := &SearchError{Index: i, Message: fmt.Sprintf(message, args...)}
+		if i >= 0 {
+			e.Search = wp.SearchList[i]
+		}
+		errs = append(errs, e)
+	}
+	if wp.WebsiteURL != "" {
+		if u, err := url.Parse(wp.WebsiteURL); err != nil || u.Scheme == "" || u.Host == "" {
+			fail(-1, "website url %q is not an absolute url", wp.WebsiteURL)
+		}
+	}
+	for _, problem := range validatePatterns(wp.URLs, wp.Hosts, wp.Paths) {
+		fail(-1, "%s", problem)
+	}
+	if wp.Schema != nil {
+		if err := wp.Schema.Compile(); err != nil {
+			fail(-1, "schema: %v", err)
+		}
+	}
+	if len(wp.SearchList) == 0 {
+		if wp.Schema == nil {
+			fail(-1, "the parser has no searches")
+		}
+		if len(errs) > 0 {
+			return errs
+		}
+		return nil
+	}
+
+	maxOrder := 0
+	for _, s := range wp.SearchList {
+		if s != nil && s.Order > maxOrder {
+			maxOrder = s.Order
+		}
+	}
+	// orders tracks what every order does, an order without searches or with only remaps matches every element
+	type orderInfo struct {
+		searches, remaps int
+		skipRemap        bool
+		forward          int
+		scope            string
+		attributes       map[string]int
+	}
+	orders := make([]*orderInfo, maxOrder+1)
+	for i := range orders {
+		orders[i] = &orderInfo{forward: -1, attributes: map[string]int{}}
+	}
+
+	for i, s := range wp.SearchList {
+		if s == nil {
+			fail(i, "the search is nil")
+			continue
+		}
+		if s.Type != TypeTag && s.Type != TypeAttribute {
+			fail(i, "unknown type %q, expected %q or %q", s.Type, TypeTag, TypeAttribute)
+		}
+		if strings.TrimSpace(s.Tag) == "" {
+			fail(i, "the tag is empty")
+		}
+		if s.Order < 0 {
+			fail(i, "the order can not be negative")
+			continue
+		}
+		if _, err := ParseTransforms(s.Transform); err != nil {
+			fail(i, "transform: %v", err)
+		}
+		o := orders[s.Order]
+		if s.OnlyRemap {
+			o.remaps++
+			if s.InternalTagName == "" && s.Transform == "" {
+				fail(i, "only_remap without an internal tag name or a transform does nothing")
+			}
+			if s.SkipRemap {
+				fail(i, "only_remap and skip_remap are both set")
+			}
+			if s.ForwardData || s.UseChildData || s.Flatten || s.Scope != "" {
+				fail(i, "forward_data, use_child_data, scope and flatten are ignored on an only_remap search")
+			}
+			continue
+		}
+		o.searches++
+		o.skipRemap = o.skipRemap || s.SkipRemap
+		scope := s.Scope
+		if s.UseChildData {
+			if scope != "" && scope != ScopeChildren {
+				fail(i, "use_child_data and scope %q are both set", scope)
+			}
+			scope = ScopeChildren
+		}
+		switch scope {
+		case "", ScopeDescendants, ScopeChildren, ScopeSiblings:
+		default:
+			fail(i, "unknown scope %q, expected %q, %q or %q", scope, ScopeDescendants, ScopeChildren, ScopeSiblings)
+		}
+		if scope != "" && o.scope != "" && scope != o.scope {
+			fail(i, "scope %q differs from scope %q of another search in the same order", scope, o.scope)
+		} else if scope != "" {
+			o.scope = scope
+		}
+		if s.ForwardData && o.forward < 0 {
+			o.forward = i
+		}
+		if s.Type == TypeAttribute {
+			if _, err := regexp.Compile(s.TagValue); err != nil {
+				fail(i, "invalid regex %q: %v", s.TagValue, err)
+			}
+			if j, found := o.attributes[s.Tag]; found && wp.SearchList[j].TagValue != s.TagValue {
+				fail(i, "attribute %q is already searched for %q by search %d in the same order", s.Tag, wp.SearchList[j].TagValue, j)
+			} else {
+				o.attributes[s.Tag] = i
+			}
+		}
+	}
+
+	forwarded := false
+	for order, o := range orders {
+		if o.scope == ScopeSiblings && !forwarded {
+			fail(-1, "order %d searches siblings but no order before it forwards its data", order)
+		}
+		forwarded = forwarded || o.forward >= 0
+		switch {
+		case o.searches == 0 && o.remaps == 0:
+			fail(-1, "no search has order %d, the order would match every element", order)
+		case o.searches == 0:
+			fail(-1, "order %d only remaps, the order would match every element", order)
+		}
+		if o.skipRemap && o.remaps > 0 {
+			fail(-1, "order %d skips remapping so its only_remap searches are never used", order)
+		}
+		if order == maxOrder && o.forward >= 0 {
+			fail(o.forward, "forward_data on the last order has no order to forward its data to")
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
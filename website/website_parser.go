@@ -2,74 +2,204 @@ package website
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 
 	"github.com/google/uuid"
 
+	"github.com/Seann-Moser/WebParser/match"
 	v2 "github.com/Seann-Moser/WebParser/v2"
 )
 
 // Parser an object that combines multiple search's to get more specific data
 // This can also be used with QueryHelper
 type Parser struct {
-	ID         string    `json:"id" db:"id" join_name:"id"`
-	Name       string    `json:"name" joinable:"false"`
-	WebsiteURL string    `json:"website_url" where:"=" joinable:"false"`
+	ID         string `json:"id" db:"id" join_name:"id"`
+	Name       string `json:"name" joinable:"false"`
+	WebsiteURL string `json:"website_url" where:"=" joinable:"false"`
+	// URLs are url patterns of the pages the parser handles, ie https://*.example.com/series/**, see MatchURL
+	URLs []string `json:"urls,omitempty" skip_table:"true"`
+	// Hosts are host patterns like example.com, which also matches www.example.com, *.example.com or regex:^cdn\d+\.
+	// Paths are path globs where * stays inside a segment and ** crosses them, or regex: patterns, any path when empty
+	// pages matching a host and a path are handled too, the host of WebsiteURL is used when there are no patterns
+	Hosts []string `json:"hosts,omitempty" skip_table:"true"`
+	Paths []string `json:"paths,omitempty" skip_table:"true"`
+	// Priority picks between parsers matching the same page in a ParserRegistry, higher wins
+	Priority   int       `json:"priority" joinable:"false"`
 	SearchList []*Search `json:"search_list" skip_table:"true"`
+	// Schema shapes the output of Extract into nested typed records, optional
+	Schema *Schema `json:"schema,omitempty" skip_table:"true"`
 }
 
 // NewWebParser creates a new parser from a list of search data
+// the parser is validated so a misconfigured site fails here instead of producing empty output, see Validate
 func NewWebParser(name, u string, searchData []*Search) (*Parser, error) {
-	return &Parser{
+	wp := &Parser{
 		ID:         uuid.New().String(),
 		Name:       name,
 		WebsiteURL: u,
 		SearchList: searchData,
-	}, nil
+	}
+	if err := wp.Validate(); err != nil {
+		return nil, err
+	}
+	return wp, nil
 }
 
 // Parse will take in an htmlSourceRequest and an url to retrieve information from that site
+// the url has to match the patterns of the parser, see MatchURL, pages that are already loaded go to ParseDocument
 func (wp *Parser) Parse(SourceReq *v2.HTMLSourceRequest, searchURL string) ([]*v2.HtmlData, []map[string]string, error) {
-	u, err := url.Parse(searchURL)
+	if !wp.MatchURL(searchURL) {
+		return nil, nil, fmt.Errorf("parser %s does not handle %s", wp.Name, searchURL)
+	}
+	source, err := SourceReq.GetSourceCode(searchURL, http.MethodGet, nil)
 	if err != nil {
 		return nil, nil, err
 	}
-	tmpUrl, err := url.Parse(wp.WebsiteURL)
+	return wp.ParseDocument(source, searchURL)
+}
+
+// ParseDocument runs the search list over a page that is already loaded, ie from a queue, a file or a POST request
+// pageURL is used to build absolute links, unlike Parse it is not checked against the patterns of the parser
+func (wp *Parser) ParseDocument(doc *v2.HtmlData, pageURL string) ([]*v2.HtmlData, []map[string]string, error) {
+	if doc == nil {
+		return nil, nil, fmt.Errorf("parser %s: no document to parse", wp.Name)
+	}
+	u, err := url.Parse(pageURL)
 	if err != nil {
 		return nil, nil, err
 	}
-	if u.Host != tmpUrl.Host {
-		return nil, nil, fmt.Errorf("host parser(%s) does not match search url: %s", tmpUrl.Host, u.Host)
-	}
-	source, err := SourceReq.GetSourceCode(searchURL, http.MethodGet, nil)
+	output, remappedOutput := wp.parse(doc, u)
+	return output, remappedOutput, nil
+}
+
+// ParseReader reads html from r and runs the search list over it, see ParseDocument
+func (wp *Parser) ParseReader(r io.Reader, pageURL string) ([]*v2.HtmlData, []map[string]string, error) {
+	doc, err := v2.NewHTMLSourceRequest().ProcessReader(r)
 	if err != nil {
 		return nil, nil, err
 	}
-	l, maxOrder := separate(wp.SearchList)
+	return wp.ParseDocument(doc, pageURL)
+}
+
+// Match is an element found by an order of the search list, Children are the matches of the orders after it found inside
+// the element when its order forwards its data
+type Match struct {
+	Order   int
+	Element *v2.HtmlData
+	// Values is the remapped output of the element, empty when the order skips remapping
+	Values   map[string]string
+	Children []*Match
+
+	// scope is the element the next orders search from, Element is a copy when the order flattens
+	scope     *v2.HtmlData
+	forwarded bool
+}
+
+// ParseTree runs the search list over a loaded page like ParseDocument and keeps the matches grouped per parent match
+func (wp *Parser) ParseTree(source *v2.HtmlData, pageURL string) ([]*Match, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	return wp.matches(source, u), nil
+}
+
+// parse runs the search list over an already loaded page, see matches
+// rows are listed per parent match, the row of a forwarding match comes before the rows found inside it
+func (wp *Parser) parse(page *v2.HtmlData, u *url.URL) ([]*v2.HtmlData, []map[string]string) {
 	var output []*v2.HtmlData
 	var remappedOutput []map[string]string
-	for i := 0; i <= maxOrder; i++ {
-		combinedData := search(l, i)
-		baseSearch := source.Search(combinedData.Tags, combinedData.Attributes, nil)
-		for index, d := range baseSearch {
-			if combinedData.Flatten {
-				baseSearch[index] = d.Flatten(nil, nil)
+	// an element found inside several parent matches is listed once per order
+	type seenMatch struct {
+		e     *v2.HtmlData
+		order int
+	}
+	seen := map[seenMatch]struct{}{}
+	var walk func(matches []*Match)
+	walk = func(matches []*Match) {
+		for _, m := range matches {
+			key := seenMatch{m.scope, m.Order}
+			if _, found := seen[key]; found {
+				continue
+			}
+			seen[key] = struct{}{}
+			if len(m.Values) > 0 {
+				remappedOutput = append(remappedOutput, m.Values)
 			}
-			r := remap(baseSearch[index], combinedData, u)
-			if len(r) > 0 {
-				remappedOutput = append(remappedOutput, r)
+			if !m.forwarded {
+				output = append(output, m.Element)
 			}
+			walk(m.Children)
+		}
+	}
+	walk(wp.matches(page, u))
+	return output, remappedOutput
+}
 
+// matches runs the orders of the search list one after the other, an order that forwards its data runs the orders after it
+// inside each of its matches, by default on every element inside the match, see Search.Scope
+// the page is never changed, flattened matches are copies
+func (wp *Parser) matches(page *v2.HtmlData, u *url.URL) []*Match {
+	l, maxOrder := separate(append([]*Search{}, wp.SearchList...))
+	orders := make([]*combinedSearch, maxOrder+1)
+	for i := range orders {
+		orders[i] = search(l, i)
+		orders[i].query = v2.SearchQuery(orders[i].Tags, orders[i].Attributes, nil, match.Default)
+	}
+	return runOrders(page, nil, orders, 0, u)
+}
+
+// runOrders runs the orders from start inside parent, siblings are the matches of the forwarding order parent was found by,
+// nil when parent is the page
+func runOrders(parent *v2.HtmlData, siblings map[*v2.HtmlData]struct{}, orders []*combinedSearch, start int, u *url.URL) []*Match {
+	var output []*Match
+	for i := start; i < len(orders); i++ {
+		cs := orders[i]
+		found, _ := cs.query.Filter(scoped(parent, siblings, cs.Scope))
+		forward := cs.ForwardData && i != len(orders)-1
+		matched := map[*v2.HtmlData]struct{}{}
+		for _, e := range found {
+			matched[e] = struct{}{}
 		}
-		if combinedData.ForwardData && i != maxOrder {
-			source.Child = baseSearch
-		} else {
-			output = append(output, baseSearch...)
+		for _, e := range found {
+			m := &Match{Order: i, Element: e, scope: e, forwarded: forward}
+			if cs.Flatten {
+				m.Element = e.Flatten(nil, nil)
+			}
+			m.Values = remap(m.Element, cs, u)
+			if forward {
+				m.Children = runOrders(e, matched, orders, i+1, u)
+			}
+			output = append(output, m)
+		}
+		if forward {
+			break
 		}
 	}
+	return output
+}
 
-	return output, remappedOutput, nil
+// scoped returns the elements an order searches, the page itself is searched with every element inside it whatever the scope
+// siblings stop at the next match of the forwarding order so every match only gets the elements up to the next one
+func scoped(parent *v2.HtmlData, siblings map[*v2.HtmlData]struct{}, scope string) []*v2.HtmlData {
+	switch {
+	case siblings == nil:
+		return parent.Elements()
+	case scope == ScopeChildren:
+		return parent.Children()
+	case scope == ScopeSiblings:
+		var output []*v2.HtmlData
+		for _, s := range parent.NextSiblings() {
+			if _, found := siblings[s]; found {
+				break
+			}
+			output = append(output, s.Elements()...)
+		}
+		return output
+	}
+	return parent.Elements()[1:]
 }
 
 func remap(d *v2.HtmlData, combinedSearch *combinedSearch, baseUrl *url.URL) map[string]string {
@@ -88,7 +218,7 @@ func remap(d *v2.HtmlData, combinedSearch *combinedSearch, baseUrl *url.URL) map
 	link, _ := d.FindLinks(fmt.Sprintf("%s://%s", baseUrl.Scheme, baseUrl.Host), []string{"href", "src", "data-src"})
 	addKeyIfExists(combinedSearch, "link", link, remapped)
 	addKeyIfExists(combinedSearch, "text", d.TextData, remapped)
-
+	combinedSearch.transform(remapped, baseUrl)
 	return remapped
 }
 
@@ -0,0 +1,188 @@
+package website
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+)
+
+const chainPage = `<html><body>
+<div class="list"><a href="/1">One</a><span><a href="/nested">Nested</a></span></div>
+<div class="list"><a href="/2">Two</a></div>
+<a href="/outside">Outside</a>
+<dl><dt>Author</dt><dd>Ann</dd><dd>Bob</dd><dt>Status</dt><dd>Ongoing</dd></dl>
+</body></html>`
+
+func loadChainPage(t *testing.T) *v2.HtmlData {
+	t.Helper()
+	page, err := v2.NewHTMLSourceRequest().ProcessSourceCode(chainPage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return page
+}
+
+func describe(nodes []*v2.HtmlData) []string {
+	var output []string
+	for _, n := range nodes {
+		output = append(output, fmt.Sprintf("%s %q", n.Tag, n.TextData))
+	}
+	return output
+}
+
+// the expected outputs of these cases were recorded with the parser before orders were chained inside their matches
+func TestParseMatchesBaseline(t *testing.T) {
+	cases := []struct {
+		name     string
+		searches []*Search
+		output   []string
+		rows     []map[string]string
+	}{
+		{
+			name: "forward_data",
+			searches: []*Search{
+				{Type: TypeAttribute, Tag: "class", TagValue: "^list$", ForwardData: true, SkipRemap: true},
+				{Type: TypeTag, Tag: "a", Order: 1},
+				{Type: TypeAttribute, Tag: "text", InternalTagName: "title", Order: 1, OnlyRemap: true},
+			},
+			output: []string{`a "One"`, `a "Nested"`, `a "Two"`},
+			rows: []map[string]string{
+				{"href": "/1", "link": "https://example.com/1", "title": "One"},
+				{"href": "/nested", "link": "https://example.com/nested", "title": "Nested"},
+				{"href": "/2", "link": "https://example.com/2", "title": "Two"},
+			},
+		},
+		{
+			name:     "use_child_data_on_the_page",
+			searches: []*Search{{Type: TypeTag, Tag: "a", UseChildData: true}},
+			output:   []string{`a "One"`, `a "Nested"`, `a "Two"`, `a "Outside"`},
+			rows: []map[string]string{
+				{"href": "/1", "link": "https://example.com/1", "text": "One"},
+				{"href": "/nested", "link": "https://example.com/nested", "text": "Nested"},
+				{"href": "/2", "link": "https://example.com/2", "text": "Two"},
+				{"href": "/outside", "link": "https://example.com/outside", "text": "Outside"},
+			},
+		},
+		{
+			name:     "flatten",
+			searches: []*Search{{Type: TypeAttribute, Tag: "class", TagValue: "^list$", Flatten: true}},
+			output:   []string{`div "One---Nested"`, `div "Two"`},
+			rows: []map[string]string{
+				{"class": "list", "href": "/1,/nested", "link": "https://example.com/1,/nested", "text": "One---Nested"},
+				{"class": "list", "href": "/2", "link": "https://example.com/2", "text": "Two"},
+			},
+		},
+		{
+			name: "orders_after_a_forward",
+			searches: []*Search{
+				{Type: TypeAttribute, Tag: "class", TagValue: "^list$", ForwardData: true, SkipRemap: true},
+				{Type: TypeTag, Tag: "span", Order: 1, SkipRemap: true},
+				{Type: TypeTag, Tag: "a", Order: 2},
+			},
+			output: []string{`span ""`, `a "One"`, `a "Nested"`, `a "Two"`},
+			rows: []map[string]string{
+				{"href": "/1", "link": "https://example.com/1", "text": "One"},
+				{"href": "/nested", "link": "https://example.com/nested", "text": "Nested"},
+				{"href": "/2", "link": "https://example.com/2", "text": "Two"},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			wp, err := NewWebParser(c.name, "https://example.com", c.searches)
+			if err != nil {
+				t.Fatal(err)
+			}
+			output, rows, err := wp.ParseDocument(loadChainPage(t), "https://example.com/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := describe(output); !reflect.DeepEqual(got, c.output) {
+				t.Errorf("output = %q, want %q", got, c.output)
+			}
+			if !reflect.DeepEqual(rows, c.rows) {
+				t.Errorf("rows = %v, want %v", rows, c.rows)
+			}
+		})
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	cases := []struct {
+		name     string
+		searches []*Search
+		output   []string
+	}{
+		{
+			name: "children",
+			searches: []*Search{
+				{Type: TypeAttribute, Tag: "class", TagValue: "^list$", ForwardData: true},
+				{Type: TypeTag, Tag: "a", Order: 1, UseChildData: true},
+			},
+			output: []string{`a "One"`, `a "Two"`},
+		},
+		{
+			name: "siblings",
+			searches: []*Search{
+				{Type: TypeTag, Tag: "dt", ForwardData: true},
+				{Type: TypeTag, Tag: "dd", Order: 1, Scope: ScopeSiblings},
+			},
+			output: []string{`dd "Ann"`, `dd "Bob"`, `dd "Ongoing"`},
+		},
+		{
+			name: "flatten_then_forward",
+			searches: []*Search{
+				{Type: TypeAttribute, Tag: "class", TagValue: "^list$", ForwardData: true, Flatten: true},
+				{Type: TypeTag, Tag: "a", Order: 1},
+			},
+			output: []string{`a "One"`, `a "Nested"`, `a "Two"`},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			wp, err := NewWebParser(c.name, "https://example.com", c.searches)
+			if err != nil {
+				t.Fatal(err)
+			}
+			page := loadChainPage(t)
+			before, _ := json.Marshal(page.Elements())
+			output, _, err := wp.ParseDocument(page, "https://example.com/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := describe(output); !reflect.DeepEqual(got, c.output) {
+				t.Errorf("output = %q, want %q", got, c.output)
+			}
+			if after, _ := json.Marshal(page.Elements()); string(after) != string(before) {
+				t.Error("the page was changed by the parser")
+			}
+		})
+	}
+}
+
+func TestParseTreeGroupsPerParent(t *testing.T) {
+	wp, err := NewWebParser("tree", "https://example.com", []*Search{
+		{Type: TypeTag, Tag: "dt", ForwardData: true},
+		{Type: TypeTag, Tag: "dd", Order: 1, Scope: ScopeSiblings},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := wp.ParseTree(loadChainPage(t), "https://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string][]string{}
+	for _, m := range tree {
+		for _, c := range m.Children {
+			got[m.Element.Text()] = append(got[m.Element.Text()], c.Element.Text())
+		}
+	}
+	want := map[string][]string{"Author": {"Ann", "Bob"}, "Status": {"Ongoing"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("groups = %v, want %v", got, want)
+	}
+}
@@ -1,12 +1,28 @@
 package website
 
-import "sort"
+import (
+	"net/url"
+	"sort"
+
+	v2 "github.com/Seann-Moser/WebParser/v2"
+)
 
 const (
 	TypeTag       = "tag"
 	TypeAttribute = "attribute"
 )
 
+// the scopes of an order running inside the matches of a forwarding order
+const (
+	// ScopeDescendants searches every element inside the matches, the default
+	ScopeDescendants = "descendants"
+	// ScopeChildren only searches the elements directly inside the matches, UseChildData is the same
+	ScopeChildren = "children"
+	// ScopeSiblings searches the elements following each match inside its parent, and everything inside them,
+	// up to the next match
+	ScopeSiblings = "siblings"
+)
+
 // Search is used to build a parser that gets data from a website
 // is an object that can be loaded into the db using QueryHelper
 type Search struct {
@@ -16,11 +32,18 @@ type Search struct {
 	TagValue        string `json:"tag_value" db:"tag_value" can_update:"true"`
 	Order           int    `json:"search_order" db:"search_order" table:"primary" can_update:"true"`
 	InternalTagName string `json:"internal_tag_name" db:"internal_tag_name" can_update:"true"`
-	ForwardData     bool   `json:"forward_data" db:"forward_data" can_update:"true"`
-	UseChildData    bool   `json:"use_child_data" db:"use_child_data" can_update:"true"`
-	Flatten         bool   `json:"flatten" db:"flatten" can_update:"true"`
-	SkipRemap       bool   `json:"skip_remap" db:"skip_remap" can_update:"true"`
-	OnlyRemap       bool   `json:"only_remap" db:"only_remap" can_update:"true"`
+	// ForwardData runs the orders after this one inside each of its matches instead of the page, see Scope
+	ForwardData bool `json:"forward_data" db:"forward_data" can_update:"true"`
+	// UseChildData is Scope ScopeChildren, like every scope it only applies inside the matches of a forwarding order
+	UseChildData bool `json:"use_child_data" db:"use_child_data" can_update:"true"`
+	Flatten      bool `json:"flatten" db:"flatten" can_update:"true"`
+	SkipRemap    bool `json:"skip_remap" db:"skip_remap" can_update:"true"`
+	OnlyRemap    bool `json:"only_remap" db:"only_remap" can_update:"true"`
+	// Scope is where the order searches when an order before it forwards its data, ScopeDescendants when empty
+	Scope string `json:"scope,omitempty" db:"scope" can_update:"true"`
+	// Transform is a pipeline applied to the output key of the search, InternalTagName or else Tag,
+	// ie "trim, regex_extract('(\d+)'), default(0)", see ParseTransforms
+	Transform string `json:"transform,omitempty" db:"transform" can_update:"true"`
 }
 
 type combinedSearch struct {
@@ -31,6 +54,12 @@ type combinedSearch struct {
 	UseChildData bool `json:"use_child_data" db:"use_child_data"`
 	Flatten      bool `json:"flatten" db:"flatten"`
 	SkipRemap    bool `json:"skip_remap"`
+	Scope        string
+	// Transforms are the pipelines of the output keys
+	Transforms map[string][]*Transform
+
+	// query is built from Tags and Attributes once per parse, its patterns are compiled on the first search
+	query *v2.Query
 }
 
 func search(m map[string][]*Search, currentOrder int) *combinedSearch {
@@ -38,6 +67,7 @@ func search(m map[string][]*Search, currentOrder int) *combinedSearch {
 		Tags:         []string{},
 		Attributes:   map[string]string{},
 		RemapValues:  map[string]string{},
+		Transforms:   map[string][]*Transform{},
 		ForwardData:  false,
 		UseChildData: false,
 	}
@@ -59,6 +89,12 @@ func search(m map[string][]*Search, currentOrder int) *combinedSearch {
 }
 
 func (s *Search) updateCombinedSearch(cs *combinedSearch) {
+	if s.Transform != "" {
+		// the pipeline was checked by Validate
+		pipeline, _ := ParseTransforms(s.Transform)
+		key := s.outputKey()
+		cs.Transforms[key] = append(cs.Transforms[key], pipeline...)
+	}
 	if s.OnlyRemap {
 		if len(s.InternalTagName) > 0 {
 			cs.RemapValues[s.Tag] = s.InternalTagName
@@ -74,6 +110,10 @@ func (s *Search) updateCombinedSearch(cs *combinedSearch) {
 
 	if s.UseChildData {
 		cs.UseChildData = s.UseChildData
+		cs.Scope = ScopeChildren
+	}
+	if s.Scope != "" && cs.Scope == "" {
+		cs.Scope = s.Scope
 	}
 	if s.ForwardData {
 		cs.ForwardData = s.ForwardData
@@ -89,6 +129,27 @@ func (s *Search) updateCombinedSearch(cs *combinedSearch) {
 	}
 }
 
+// outputKey is the key of the remapped output the search writes to
+func (s *Search) outputKey() string {
+	if s.InternalTagName != "" {
+		return s.InternalTagName
+	}
+	return s.Tag
+}
+
+// transform runs the pipelines over the remapped output, a value is removed when its pipeline fails or leaves it empty
+// and keys that are missing go through their pipeline too so default can fill them
+func (cs *combinedSearch) transform(remapped map[string]string, u *url.URL) {
+	for key, pipeline := range cs.Transforms {
+		v, err := ApplyTransforms(remapped[key], pipeline, u)
+		if err != nil || v == "" {
+			delete(remapped, key)
+			continue
+		}
+		remapped[key] = v
+	}
+}
+
 func separate(searchList []*Search) (map[string][]*Search, int) {
 	sort.Slice(searchList, func(i, j int) bool {
 		return searchList[i].Order < searchList[j].Order